@@ -24,15 +24,36 @@ import (
 // to as prior nodes as possible before trying to tightly pack executors
 // while also trying to ensure that we can fit everything in a single AZ.
 // If it cannot fit into a single AZ it falls back to the TightlyPack SparkBinPackFunction.
-var AzAwareTightlyPack = SparkBinPackFunction(func(
+var AzAwareTightlyPack = getAzAwareSparkBinFunction(tightlyPackExecutors)
+
+// getAzAwareSparkBinFunction packs like getSingleAZSparkBinFunction, and falls back to SparkBinPack across zones
+func getAzAwareSparkBinFunction(fn GenericBinPackFunction) SparkBinPackFunction {
+	singleAZ := getSingleAZSparkBinFunction(fn)
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+		packingResult := singleAZ(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+		if packingResult.HasCapacity || packingResult.TimedOut {
+			return packingResult
+		}
+		return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, fn)
+	})
+}
+
+// AzAwareTightlyPackMultiProfile is a MultiProfileSparkBinPackFunction that tries SingleAZTightlyPackMultiProfile
+// first, and falls back to TightlyPackMultiProfile when the application does not fit into a single AZ
+var AzAwareTightlyPackMultiProfile = MultiProfileSparkBinPackFunction(func(
 	ctx context.Context,
-	driverResources, executorResources *resources.Resources,
-	executorCount int,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
 	driverNodePriorityOrder, executorNodePriorityOrder []string,
 	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
-	packingResult := SingleAZTightlyPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
-	if packingResult.HasCapacity {
+	packingResult := SingleAZTightlyPackMultiProfile(ctx, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+	if packingResult.HasCapacity || packingResult.TimedOut {
 		return packingResult
 	}
-	return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, tightlyPackExecutors)
+	return SparkBinPackMultiProfile(ctx, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, tightlyPackExecutors)
 })
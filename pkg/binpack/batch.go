@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// BatchPolicy decides which of the pending applications of a batch are placed
+type BatchPolicy string
+
+const (
+	// BatchFIFOStrict places applications in queue order and stops at the first one that does not fit, so a large
+	// application at the head of the queue is never overtaken
+	BatchFIFOStrict BatchPolicy = "fifo-strict"
+	// BatchFIFOWithBackfill places applications in queue order and skips the ones that do not fit, so later
+	// applications can use the capacity an earlier one could not
+	BatchFIFOWithBackfill BatchPolicy = "fifo-with-backfill"
+	// BatchMaximizeApplications places as many applications as possible by trying the smallest ones first, where
+	// the size of an application is its dominant share of the available resources of all nodes. This is a greedy
+	// approximation, ties are broken by queue order.
+	BatchMaximizeApplications BatchPolicy = "maximize-applications"
+)
+
+// PendingApplication is an application waiting to be placed
+type PendingApplication struct {
+	DriverResources   *resources.Resources
+	ExecutorResources *resources.Resources
+	ExecutorCount     int
+	// ExecutorConstraints constrain where the executors of the application are placed
+	ExecutorConstraints ExecutorConstraints
+	// DriverPlacementPolicy constrains where the driver is placed relative to the executors
+	DriverPlacementPolicy DriverPlacementPolicy
+}
+
+// BatchPackingResult is the result of packing a batch of applications
+type BatchPackingResult struct {
+	// PackingResults holds the packing result of each application, in queue order. Applications that were not
+	// placed have a packing result without capacity, which is timed out if packing was interrupted before or while they
+	// were tried.
+	PackingResults []*PackingResult
+	// Reserved holds the resources reserved on each node by all placed applications
+	Reserved resources.NodeGroupResources
+}
+
+// PackBatch places applications, ordered as they are queued, on one set of nodes like SparkBinPackWithDriverPlacement,
+// using distributeExecutors with the executor constraints of each application. Every placed application reserves
+// its resources in a ledger shared by the batch before the next one is packed, so the applications share the
+// capacity of the nodes. policy decides which applications are placed.
+func PackBatch(
+	ctx context.Context,
+	distributeExecutors GenericBinPackFunction,
+	applications []PendingApplication,
+	policy BatchPolicy,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) (*BatchPackingResult, error) {
+
+	var order []int
+	switch policy {
+	case BatchFIFOStrict, BatchFIFOWithBackfill:
+		order = make([]int, len(applications))
+		for i := range order {
+			order[i] = i
+		}
+	case BatchMaximizeApplications:
+		order = smallestApplicationsFirst(applications, nodesSchedulingMetadata)
+	default:
+		return nil, werror.Error("unknown batch policy",
+			werror.SafeParam("batchPolicy", string(policy)))
+	}
+
+	result := &BatchPackingResult{
+		PackingResults: make([]*PackingResult, len(applications)),
+	}
+	reserved := resources.NewLedger(nil)
+	// skipped is set once BatchFIFOStrict stops, and builds the result of every application after that
+	var skipped func() *PackingResult
+	for _, i := range order {
+		if skipped != nil {
+			result.PackingResults[i] = skipped()
+			continue
+		}
+		if isDone(ctx) {
+			result.PackingResults[i] = TimedOutPackingResult()
+			continue
+		}
+		application := applications[i]
+		executorGroups := []ExecutorGroup{{Resources: application.ExecutorResources, Count: application.ExecutorCount}}
+		placement, interrupted := sparkBinPackExecutorGroups(
+			ctx,
+			application.DriverPlacementPolicy,
+			application.DriverResources,
+			executorGroups,
+			driverNodePriorityOrder,
+			executorNodePriorityOrder,
+			nodesSchedulingMetadata,
+			reserved,
+			WithExecutorConstraints(distributeExecutors, application.ExecutorConstraints))
+		if interrupted {
+			result.PackingResults[i] = TimedOutPackingResult()
+			if policy == BatchFIFOStrict {
+				skipped = TimedOutPackingResult
+			}
+			continue
+		}
+		if placement == nil {
+			result.PackingResults[i] = EmptyPackingResult()
+			if policy == BatchFIFOStrict {
+				skipped = EmptyPackingResult
+			}
+			continue
+		}
+		result.PackingResults[i] = &PackingResult{
+			DriverNode:          placement.driverNode,
+			ExecutorNodes:       placement.executorNodes,
+			HasCapacity:         true,
+			PackingEfficiencies: computePackingEfficiencies(nodesSchedulingMetadata, placement.reserved),
+		}
+		reserved.Merge(placement.reserved)
+	}
+	result.Reserved = reserved.Resources()
+	return result, nil
+}
+
+// smallestApplicationsFirst returns the indices of applications sorted by their dominant share of the available
+// resources of all nodes, keeping queue order for ties
+func smallestApplicationsFirst(applications []PendingApplication, nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) []int {
+	allResources := make([]*resources.Resources, 0, 2*len(applications))
+	for _, application := range applications {
+		allResources = append(allResources, application.DriverResources, application.ExecutorResources)
+	}
+	dimensions := resources.DimensionsOf(allResources...)
+	available := make(resources.Vector, len(dimensions))
+	for _, nodeSchedulingMetadata := range nodesSchedulingMetadata {
+		available.Add(dimensions.Vector(nodeSchedulingMetadata.AvailableResources))
+	}
+
+	shares := make([]float64, len(applications))
+	order := make([]int, len(applications))
+	for i, application := range applications {
+		order[i] = i
+		requested := dimensions.Vector(application.DriverResources)
+		requested.Add(dimensions.Vector(application.ExecutorResources).Times(application.ExecutorCount))
+		for d := range dimensions {
+			if requested[d] == 0 {
+				continue
+			}
+			if available[d] <= 0 {
+				// nothing is available, so the application can not be placed
+				shares[i] = math.Inf(1)
+				break
+			}
+			shares[i] = math.Max(shares[i], float64(requested[d])/float64(available[d]))
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return shares[order[a]] < shares[order[b]]
+	})
+	return order
+}
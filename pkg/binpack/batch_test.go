@@ -0,0 +1,171 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestPackBatch(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(5, 5, 0, "zone1"),
+	}
+	nodePriorityOrder := []string{"n1", "n2"}
+	application := func(executorCount int) PendingApplication {
+		return PendingApplication{
+			DriverResources:   resources.CreateResources(1, 1, 0),
+			ExecutorResources: resources.CreateResources(1, 1, 0),
+			ExecutorCount:     executorCount,
+		}
+	}
+	// 10, 2, 6 and 1 pods, on 13 slots
+	applications := []PendingApplication{application(9), application(1), application(5), application(0)}
+
+	tests := []struct {
+		name             string
+		policy           BatchPolicy
+		expectedPlaced   []bool
+		expectedReserved int64
+	}{{
+		name:             "fifo strict stops at the first application that does not fit",
+		policy:           BatchFIFOStrict,
+		expectedPlaced:   []bool{true, true, false, false},
+		expectedReserved: 12,
+	}, {
+		name:             "fifo with backfill skips applications that do not fit",
+		policy:           BatchFIFOWithBackfill,
+		expectedPlaced:   []bool{true, true, false, true},
+		expectedReserved: 13,
+	}, {
+		name:             "maximize applications places small applications first",
+		policy:           BatchMaximizeApplications,
+		expectedPlaced:   []bool{false, true, true, true},
+		expectedReserved: 9,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := PackBatch(context.Background(), TightlyPackExecutors, applications, test.policy, nodePriorityOrder, nodePriorityOrder, nodesSchedulingMetadata)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			placed := make([]bool, 0, len(result.PackingResults))
+			for _, packingResult := range result.PackingResults {
+				placed = append(placed, packingResult.HasCapacity)
+			}
+			if !reflect.DeepEqual(test.expectedPlaced, placed) {
+				t.Fatalf("mismatch in placed applications, expected: %v, got: %v", test.expectedPlaced, placed)
+			}
+
+			reserved := resources.Zero()
+			for nodeName, nodeReserved := range result.Reserved {
+				if nodeReserved.GreaterThan(nodesSchedulingMetadata[nodeName].AvailableResources) {
+					t.Fatalf("node %s is overcommitted", nodeName)
+				}
+				reserved.Add(nodeReserved)
+			}
+			if !reserved.Eq(resources.CreateResources(test.expectedReserved, test.expectedReserved, 0)) {
+				t.Fatalf("reserved %v", reserved)
+			}
+		})
+	}
+}
+
+func TestPackBatchErrors(t *testing.T) {
+	_, err := PackBatch(context.Background(), TightlyPackExecutors, nil, BatchPolicy("unknown"), nil, nil, resources.NodeGroupSchedulingMetadata{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	application := PendingApplication{DriverResources: resources.Zero(), ExecutorResources: resources.Zero()}
+	result, err := PackBatch(ctx, TightlyPackExecutors, []PendingApplication{application}, BatchFIFOStrict, nil, nil, resources.NodeGroupSchedulingMetadata{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.PackingResults[0].TimedOut {
+		t.Fatalf("expected packing to time out")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := func(ctx context.Context, _ *resources.Resources, _ int, _ []string, _ resources.NodeGroupSchedulingMetadata, _ *resources.Ledger) ([]string, bool) {
+		cancel()
+		return nil, ctx.Err() == nil
+	}
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+	}
+	application = PendingApplication{
+		DriverResources:   resources.CreateResources(1, 1, 0),
+		ExecutorResources: resources.CreateResources(1, 1, 0),
+		ExecutorCount:     1,
+	}
+	applications := []PendingApplication{application, application, application}
+	result, err = PackBatch(ctx, interrupt, applications, BatchFIFOStrict, []string{"n1"}, []string{"n1"}, nodesSchedulingMetadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, packingResult := range result.PackingResults {
+		if packingResult.HasCapacity || !packingResult.TimedOut {
+			t.Fatalf("expected application %v to time out after the interruption, got: %+v", i, packingResult)
+		}
+	}
+}
+
+func TestPackBatchConstraints(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"n3": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+	}
+	nodePriorityOrder := []string{"n1", "n2", "n3"}
+	applications := []PendingApplication{{
+		DriverResources:   resources.CreateResources(3, 3, 0),
+		ExecutorResources: resources.CreateResources(1, 1, 0),
+		ExecutorCount:     0,
+	}, {
+		DriverResources:       resources.CreateResources(1, 1, 0),
+		ExecutorResources:     resources.CreateResources(1, 1, 0),
+		ExecutorCount:         4,
+		ExecutorConstraints:   ExecutorConstraints{MaxExecutorsPerNode: 2},
+		DriverPlacementPolicy: DriverPlacementIsolated,
+	}}
+
+	result, err := PackBatch(context.Background(), TightlyPackExecutors, applications, BatchFIFOStrict, nodePriorityOrder, nodePriorityOrder, nodesSchedulingMetadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.PackingResults[1].HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	// the first application leaves room for one pod on n1, which the driver takes as executors may not share its node
+	if result.PackingResults[1].DriverNode != "n1" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "n1", result.PackingResults[1].DriverNode)
+	}
+	expectedExecutorNodes := []string{"n2", "n2", "n3", "n3"}
+	if !reflect.DeepEqual(expectedExecutorNodes, result.PackingResults[1].ExecutorNodes) {
+		t.Fatalf("mismatch in executor nodes, expected: %v, got: %v", expectedExecutorNodes, result.PackingResults[1].ExecutorNodes)
+	}
+	if !result.Reserved["n1"].Eq(resources.CreateResources(4, 4, 0)) {
+		t.Fatalf("reserved %v", result.Reserved["n1"])
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+const (
+	benchmarkNodeCount     = 5000
+	benchmarkExecutorCount = 2000
+)
+
+// syntheticCluster returns a cluster of mostly full nodes spread across three zones, so that packing an application
+// has to look at a large part of the priority list
+func syntheticCluster(nodeCount int) ([]string, resources.NodeGroupSchedulingMetadata) {
+	random := rand.New(rand.NewSource(42))
+	nodeNames := make([]string, 0, nodeCount)
+	nodesSchedulingMetadata := make(resources.NodeGroupSchedulingMetadata, nodeCount)
+	for i := 0; i < nodeCount; i++ {
+		nodeName := fmt.Sprintf("node-%d", i)
+		nodeNames = append(nodeNames, nodeName)
+		nodesSchedulingMetadata[nodeName] = resources.CreateSchedulingMetadataWithTotals(
+			random.Int63n(4), 16, random.Int63n(16)<<30, 64<<30, 0, 0, fmt.Sprintf("zone-%d", i%3))
+	}
+	return nodeNames, nodesSchedulingMetadata
+}
+
+func benchmarkSparkBinPackFunction(b *testing.B, binpacker SparkBinPackFunction) {
+	nodeNames, nodesSchedulingMetadata := syntheticCluster(benchmarkNodeCount)
+	driverResources := resources.CreateResources(1, 2<<30, 0)
+	executorResources := resources.CreateResources(1, 4<<30, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binpacker(context.Background(), driverResources, executorResources, benchmarkExecutorCount, nodeNames, nodeNames, nodesSchedulingMetadata)
+	}
+}
+
+func BenchmarkTightlyPack(b *testing.B) {
+	benchmarkSparkBinPackFunction(b, TightlyPack)
+}
+
+func BenchmarkDistributeEvenly(b *testing.B) {
+	benchmarkSparkBinPackFunction(b, DistributeEvenly)
+}
+
+func BenchmarkMinimalFragmentation(b *testing.B) {
+	benchmarkSparkBinPackFunction(b, MinimalFragmentation)
+}
+
+func BenchmarkSingleAZTightlyPack(b *testing.B) {
+	benchmarkSparkBinPackFunction(b, SingleAZTightlyPack)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+// BestDriver returns a SparkBinPackFunction that, unlike SparkBinPack, does not stop at the first driver node that
+// leads to a feasible placement. It places executors with distributeExecutors around up to maxDriverCandidates
+// feasible driver nodes in priority order, or around all of them when maxDriverCandidates is not positive, and
+// returns the placement with the highest score. Ties go to the driver node with the higher priority. A nil scorer
+// defaults to AvgPackingEfficiencyScorer.
+//
+// When ctx is done before the search finishes, see BestEffortOnTimeout.
+func BestDriver(distributeExecutors GenericBinPackFunction, maxDriverCandidates int, scorer PlacementScorer, options ...SearchOption) SparkBinPackFunction {
+	searchOptions := newSearchOptions(options)
+	if scorer == nil {
+		scorer = AvgPackingEfficiencyScorer
+	}
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		executorGroups := []ExecutorGroup{{Resources: executorResources, Count: executorCount}}
+		application := ApplicationResources{Driver: driverResources, ExecutorGroups: executorGroups}
+		var bestResult *PackingResult
+		bestScore := 0.0
+		candidates := 0
+		noReservations := resources.NewLedger(nil)
+
+		for _, driverNodeName := range driverNodePriorityOrder {
+			if isDone(ctx) {
+				return searchOptions.timedOut(bestResult)
+			}
+			if maxDriverCandidates > 0 && candidates == maxDriverCandidates {
+				break
+			}
+			attemptCtx := observeContext(ctx)
+			executorNodes, _, reserved, ok := packWithDriverNode(
+				attemptCtx, driverResources, driverNodeName, executorGroups, executorNodePriorityOrder, nodesSchedulingMetadata,
+				noReservations, distributeExecutors)
+			if !ok && attemptCtx.interrupted() {
+				return searchOptions.timedOut(bestResult)
+			}
+			if !ok {
+				continue
+			}
+			candidates++
+			// scorers may read the efficiency of any node, so candidates carry the efficiencies of all nodes
+			packingResult := &PackingResult{
+				DriverNode:          driverNodeName,
+				ExecutorNodes:       executorNodes,
+				HasCapacity:         true,
+				PackingEfficiencies: computePackingEfficiencies(nodesSchedulingMetadata, reserved),
+			}
+			if score := scorer.Score(nodesSchedulingMetadata, application, packingResult); bestResult == nil || score > bestScore {
+				bestResult, bestScore = packingResult, score
+			}
+		}
+
+		if bestResult == nil {
+			return EmptyPackingResult()
+		}
+		return bestResult
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestBestDriver(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"big":   resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone1"),
+		"small": resources.CreateSchedulingMetadataWithTotals(2, 2, 2, 2, 0, 0, "zone1"),
+		"idle":  resources.CreateSchedulingMetadataWithTotals(4, 4, 4, 4, 0, 0, "zone1"),
+	})
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name                string
+		ctx                 context.Context
+		maxDriverCandidates int
+		scorer              PlacementScorer
+		willFit             bool
+		timedOut            bool
+		expectedDriverNode  string
+	}{{
+		name:               "picks the driver node with the most efficient placement",
+		ctx:                context.Background(),
+		willFit:            true,
+		expectedDriverNode: "small",
+	}, {
+		name:                "only evaluates up to max driver candidates",
+		ctx:                 context.Background(),
+		maxDriverCandidates: 1,
+		willFit:             true,
+		expectedDriverNode:  "big",
+	}, {
+		name: "uses the given scorer",
+		ctx:  context.Background(),
+		scorer: PlacementScorerFunc(func(_ resources.NodeGroupSchedulingMetadata, _ ApplicationResources, p *PackingResult) float64 {
+			if p.DriverNode == "big" {
+				return 1
+			}
+			return 0
+		}),
+		willFit:            true,
+		expectedDriverNode: "big",
+	}, {
+		name: "scores candidates with the efficiencies of all nodes",
+		ctx:  context.Background(),
+		scorer: PlacementScorerFunc(func(_ resources.NodeGroupSchedulingMetadata, _ ApplicationResources, p *PackingResult) float64 {
+			if p.PackingEfficiencies["idle"] == nil {
+				return -1
+			}
+			if p.DriverNode == "small" {
+				return 1
+			}
+			return 0
+		}),
+		willFit:            true,
+		expectedDriverNode: "small",
+	}, {
+		name:     "stops searching when the context is done",
+		ctx:      cancelledCtx,
+		willFit:  false,
+		timedOut: true,
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := BestDriver(TightlyPackExecutors, test.maxDriverCandidates, test.scorer)(
+				test.ctx,
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				1,
+				[]string{"big", "small"},
+				[]string{"small", "big"},
+				nodesSchedulingMetadata)
+			if p.HasCapacity != test.willFit {
+				t.Fatalf("mismatch in willFit, expected: %v, got: %v", test.willFit, p.HasCapacity)
+			}
+			if p.TimedOut != test.timedOut {
+				t.Fatalf("mismatch in timed out, expected: %v, got: %v", test.timedOut, p.TimedOut)
+			}
+			if p.DriverNode != test.expectedDriverNode {
+				t.Fatalf("mismatch in driver node, expected: %v, got: %v", test.expectedDriverNode, p.DriverNode)
+			}
+			if test.willFit {
+				if len(p.PackingEfficiencies) != len(nodesSchedulingMetadata) {
+					t.Fatalf("expected %v packing efficiencies, got: %v", len(nodesSchedulingMetadata), p.PackingEfficiencies)
+				}
+			}
+		})
+	}
+}
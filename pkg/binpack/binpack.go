@@ -17,16 +17,32 @@ package binpack
 import (
 	"context"
 
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/capacity"
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
 )
 
 // PackingResult is a result of one binpacking operation. When successful, assigns driver and
 // executors to nodes. Includes an overview of the resource assignment across nodes.
 type PackingResult struct {
-	DriverNode          string
-	ExecutorNodes       []string
+	DriverNode    string
+	ExecutorNodes []string
+	// ExtraExecutorNodes is only populated by elastic packing, and holds the executors placed on top of the
+	// guaranteed minimum in ExecutorNodes
+	ExtraExecutorNodes []string
+	// ExecutorProfiles is only populated by multi profile packing, and holds the index of the ExecutorGroup
+	// of each executor in ExecutorNodes
+	ExecutorProfiles    []int
 	PackingEfficiencies map[string]*PackingEfficiency
 	HasCapacity         bool
+	// Explanation is only populated by WithExplanation when packing fails
+	Explanation *PackingExplanation
+	// FilteredNodes is only populated when node filters are used, see WithNodeFilters
+	FilteredNodes []FilteredNode
+	// TimedOut is set when ctx was done before packing finished, see TimedOutPackingResult and BestEffortOnTimeout
+	TimedOut bool
+	// ScaleDownImpact is only populated by ScaleDownFriendly when the application had to be placed on
+	// scale down candidates
+	ScaleDownImpact *ScaleDownImpact
 }
 
 // EmptyPackingResult returns a representation of the worst possible packing result.
@@ -34,11 +50,27 @@ func EmptyPackingResult() *PackingResult {
 	return &PackingResult{
 		DriverNode:          "",
 		ExecutorNodes:       make([]string, 0),
+		ExtraExecutorNodes:  make([]string, 0),
+		ExecutorProfiles:    make([]int, 0),
 		HasCapacity:         false,
 		PackingEfficiencies: make(map[string]*PackingEfficiency, 0),
 	}
 }
 
+// TimedOutPackingResult returns a representation of a binpacking operation that was interrupted because its
+// context was done. Unlike EmptyPackingResult it does not mean the application does not fit.
+func TimedOutPackingResult() *PackingResult {
+	packingResult := EmptyPackingResult()
+	packingResult.TimedOut = true
+	return packingResult
+}
+
+// ExecutorGroup is a number of executors that all request the same resources
+type ExecutorGroup struct {
+	Resources *resources.Resources
+	Count     int
+}
+
 // SparkBinPackFunction is a function type for assigning nodes to spark drivers and executors
 type SparkBinPackFunction func(
 	ctx context.Context,
@@ -47,14 +79,24 @@ type SparkBinPackFunction func(
 	driverNodePriorityOrder, executorNodePriorityOrder []string,
 	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult
 
-// GenericBinPackFunction is a function type for assigning nodes to a batch of equivalent pods
+// MultiProfileSparkBinPackFunction is a function type for assigning nodes to spark drivers and executors with
+// heterogeneous resource requests
+type MultiProfileSparkBinPackFunction func(
+	ctx context.Context,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult
+
+// GenericBinPackFunction is a function type for assigning nodes to a batch of equivalent pods. When all pods fit,
+// implementations tentatively reserve their resources in reservedResources, otherwise they leave it unchanged.
 type GenericBinPackFunction func(
 	ctx context.Context,
 	itemResources *resources.Resources,
 	itemCount int,
 	nodePriorityOrder []string,
 	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
-	reservedResources resources.NodeGroupResources) (nodes []string, hasCapacity bool)
+	reservedResources *resources.Ledger) (nodes []string, hasCapacity bool)
 
 // SparkBinPack places the driver first and calls distributeExecutors function to place executors
 func SparkBinPack(
@@ -64,24 +106,176 @@ func SparkBinPack(
 	driverNodePriorityOrder, executorNodePriorityOrder []string,
 	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
 	distributeExecutors GenericBinPackFunction) *PackingResult {
+	executorGroups := []ExecutorGroup{{Resources: executorResources, Count: executorCount}}
+	placement, interrupted := sparkBinPackExecutorGroups(
+		ctx, DriverPlacementAny, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata,
+		resources.NewLedger(nil), distributeExecutors)
+	if interrupted {
+		return TimedOutPackingResult()
+	}
+	if placement == nil {
+		return EmptyPackingResult()
+	}
+	return &PackingResult{
+		DriverNode:          placement.driverNode,
+		ExecutorNodes:       placement.executorNodes,
+		HasCapacity:         true,
+		PackingEfficiencies: computePackingEfficiencies(nodesSchedulingMetadata, placement.reserved),
+	}
+}
+
+// SparkBinPackMultiProfile places the driver first and calls distributeExecutors function once per executor group,
+// in the given order, to place executors. All groups share the same per-node capacity accounting.
+func SparkBinPackMultiProfile(
+	ctx context.Context,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	distributeExecutors GenericBinPackFunction) *PackingResult {
+	placement, interrupted := sparkBinPackExecutorGroups(
+		ctx, DriverPlacementAny, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata,
+		resources.NewLedger(nil), distributeExecutors)
+	if interrupted {
+		return TimedOutPackingResult()
+	}
+	if placement == nil {
+		return EmptyPackingResult()
+	}
+	return &PackingResult{
+		DriverNode:          placement.driverNode,
+		ExecutorNodes:       placement.executorNodes,
+		ExecutorProfiles:    placement.executorProfiles,
+		HasCapacity:         true,
+		PackingEfficiencies: computePackingEfficiencies(nodesSchedulingMetadata, placement.reserved),
+	}
+}
+
+// executorGroupsPlacement is where sparkBinPackExecutorGroups placed an application, with reserved holding the
+// resources reserved by the driver and executors
+type executorGroupsPlacement struct {
+	driverNode       string
+	executorNodes    []string
+	executorProfiles []int
+	reserved         *resources.Ledger
+}
+
+// sparkBinPackExecutorGroups places the driver and executors on top of reservedResources, which it does not change.
+// The returned placement holds a snapshot of reservedResources with the resources reserved by both, and is nil when
+// the application does not fit or the search was interrupted. interrupted is true when ctx was done before all
+// driver candidates were tried, or when an attempt failed after seeing ctx done, see observedContext.
+func sparkBinPackExecutorGroups(
+	ctx context.Context,
+	driverPlacementPolicy DriverPlacementPolicy,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	distributeExecutors GenericBinPackFunction) (placement *executorGroupsPlacement, interrupted bool) {
 	for _, driverNodeName := range driverNodePriorityOrder {
-		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[driverNodeName]
-		if !ok || driverResources.GreaterThan(nodeSchedulingMetadata.AvailableResources) {
-			continue
+		if isDone(ctx) {
+			return nil, true
+		}
+		attemptCtx := observeContext(ctx)
+		driverExecutorNodePriorityOrder := driverPlacementPolicy.executorNodePriorityOrder(
+			driverNodeName, executorNodePriorityOrder, nodesSchedulingMetadata)
+		executorNodes, executorProfiles, reserved, ok := packWithDriverNode(
+			attemptCtx, driverResources, driverNodeName, executorGroups, driverExecutorNodePriorityOrder,
+			nodesSchedulingMetadata, reservedResources, distributeExecutors)
+		if ok && !driverPlacementPolicy.accepts(driverNodeName, executorNodes) {
+			executorNodes, executorProfiles, reserved, ok = packWithDriverNode(
+				attemptCtx, driverResources, driverNodeName, executorGroups, driverExecutorNodePriorityOrder,
+				nodesSchedulingMetadata, reservedResources, withExecutorOnNode(driverNodeName, distributeExecutors))
 		}
-		reserved := make(resources.NodeGroupResources, len(nodesSchedulingMetadata))
-		reserved[driverNodeName] = driverResources.Copy()
-		executorNodes, ok := distributeExecutors(
-			ctx, executorResources, executorCount, executorNodePriorityOrder, nodesSchedulingMetadata, reserved)
 		if ok {
-			packingEfficiencies := ComputePackingEfficiencies(nodesSchedulingMetadata, reserved)
-			return &PackingResult{
-				DriverNode:          driverNodeName,
-				ExecutorNodes:       executorNodes,
-				HasCapacity:         true,
-				PackingEfficiencies: packingEfficiencies,
-			}
+			return &executorGroupsPlacement{driverNodeName, executorNodes, executorProfiles, reserved}, false
 		}
+		if attemptCtx.interrupted() {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+// packWithDriverNode places the driver on driverNodeName and the executors around it on top of reservedResources,
+// returning a snapshot of reservedResources with the resources reserved by both
+func packWithDriverNode(
+	ctx context.Context,
+	driverResources *resources.Resources,
+	driverNodeName string,
+	executorGroups []ExecutorGroup,
+	executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	distributeExecutors GenericBinPackFunction) ([]string, []int, *resources.Ledger, bool) {
+	if capacity.GetNodeCapacityFuncFromLedger(nodesSchedulingMetadata, reservedResources, driverResources)(driverNodeName) < 1 {
+		return nil, nil, nil, false
+	}
+	reserved := reservedResources.Snapshot()
+	reserved.Reserve(driverNodeName, driverResources)
+	executorNodes, executorProfiles, ok := distributeExecutorGroups(
+		ctx, executorGroups, executorNodePriorityOrder, nodesSchedulingMetadata, reserved, distributeExecutors)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	reserved.Commit()
+	return executorNodes, executorProfiles, reserved, true
+}
+
+func distributeExecutorGroups(
+	ctx context.Context,
+	executorGroups []ExecutorGroup,
+	executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reserved *resources.Ledger,
+	distributeExecutors GenericBinPackFunction) ([]string, []int, bool) {
+	executorNodes := make([]string, 0)
+	executorProfiles := make([]int, 0)
+	for profile, executorGroup := range executorGroups {
+		if isDone(ctx) {
+			return nil, nil, false
+		}
+		groupExecutorNodes, ok := distributeExecutors(
+			ctx, executorGroup.Resources, executorGroup.Count, executorNodePriorityOrder, nodesSchedulingMetadata, reserved)
+		if !ok {
+			return nil, nil, false
+		}
+		executorNodes = append(executorNodes, groupExecutorNodes...)
+		for range groupExecutorNodes {
+			executorProfiles = append(executorProfiles, profile)
+		}
+	}
+	return executorNodes, executorProfiles, true
+}
+
+// totalExecutorCount returns the number of executors in all executor groups
+func totalExecutorCount(executorGroups []ExecutorGroup) int {
+	count := 0
+	for _, executorGroup := range executorGroups {
+		count += executorGroup.Count
+	}
+	return count
+}
+
+// executorCapacity returns a function that computes how many more executors fit on a node, given the resources
+// already reserved on it
+func executorCapacity(
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	executorResources *resources.Resources) func(nodeName string) int {
+	return capacity.GetNodeCapacityFuncFromLedger(nodesSchedulingMetadata, reservedResources, executorResources)
+}
+
+// reserveExecutors tentatively reserves the resources of every executor on its node
+func reserveExecutors(executorNodes []string, executorResources *resources.Resources, reservedResources *resources.Ledger) {
+	executorCounts := make(map[string]int)
+	for _, n := range executorNodes {
+		executorCounts[n]++
+	}
+	dimensions := resources.DimensionsOf(executorResources)
+	executor := dimensions.Vector(executorResources)
+	for n, count := range executorCounts {
+		reservedResources.ReserveVectorTimes(dimensions, n, executor, count)
 	}
-	return EmptyPackingResult()
 }
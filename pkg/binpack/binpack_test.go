@@ -0,0 +1,152 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestMultiProfile(t *testing.T) {
+	tests := []struct {
+		name                    string
+		binpacker               MultiProfileSparkBinPackFunction
+		driverResources         *resources.Resources
+		executorGroups          []ExecutorGroup
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata
+		nodePriorityOrder       []string
+		expectedDriverNode      string
+		willFit                 bool
+		expectedCounts          map[string]int
+		expectedProfiles        []int
+	}{{
+		name:            "gpu and cpu executors share node capacity",
+		binpacker:       TightlyPackMultiProfile,
+		driverResources: resources.CreateResources(1, 1, 0),
+		executorGroups: []ExecutorGroup{
+			{Resources: resources.CreateResources(2, 2, 1), Count: 2},
+			{Resources: resources.CreateResources(2, 2, 0), Count: 2},
+		},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(7, 7, 2, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		}),
+		nodePriorityOrder:  []string{"n1", "n2"},
+		expectedDriverNode: "n1",
+		willFit:            true,
+		expectedCounts:     map[string]int{"n1": 3, "n2": 1},
+		expectedProfiles:   []int{0, 0, 1, 1},
+	}, {
+		name:            "distributes each group evenly",
+		binpacker:       DistributeEvenlyMultiProfile,
+		driverResources: resources.CreateResources(1, 1, 0),
+		executorGroups: []ExecutorGroup{
+			{Resources: resources.CreateResources(1, 1, 0), Count: 2},
+			{Resources: resources.CreateResources(2, 2, 0), Count: 2},
+		},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		}),
+		nodePriorityOrder:  []string{"n1", "n2"},
+		expectedDriverNode: "n1",
+		willFit:            true,
+		expectedCounts:     map[string]int{"n1": 2, "n2": 2},
+		expectedProfiles:   []int{0, 0, 1, 1},
+	}, {
+		name:            "does not fit when a later group does not fit in the remaining capacity",
+		binpacker:       MinimalFragmentationMultiProfile,
+		driverResources: resources.CreateResources(1, 1, 0),
+		executorGroups: []ExecutorGroup{
+			{Resources: resources.CreateResources(4, 4, 0), Count: 2},
+			{Resources: resources.CreateResources(4, 4, 0), Count: 1},
+		},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(5, 5, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(5, 5, 0, "zone1"),
+		}),
+		nodePriorityOrder: []string{"n1", "n2"},
+		willFit:           false,
+	}, {
+		name:            "single AZ places all groups in the same zone",
+		binpacker:       SingleAZTightlyPackMultiProfile,
+		driverResources: resources.CreateResources(1, 1, 0),
+		executorGroups: []ExecutorGroup{
+			{Resources: resources.CreateResources(2, 2, 0), Count: 1},
+			{Resources: resources.CreateResources(1, 1, 0), Count: 2},
+		},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1_z1": resources.CreateSchedulingMetadata(4, 4, 0, "z1"),
+			"n1_z2": resources.CreateSchedulingMetadata(8, 8, 0, "z2"),
+		}),
+		nodePriorityOrder:  []string{"n1_z1", "n1_z2"},
+		expectedDriverNode: "n1_z2",
+		willFit:            true,
+		expectedCounts:     map[string]int{"n1_z2": 3},
+		expectedProfiles:   []int{0, 1, 1},
+	}, {
+		name:            "az aware falls back to packing across zones",
+		binpacker:       AzAwareTightlyPackMultiProfile,
+		driverResources: resources.CreateResources(1, 1, 0),
+		executorGroups: []ExecutorGroup{
+			{Resources: resources.CreateResources(2, 2, 0), Count: 1},
+			{Resources: resources.CreateResources(2, 2, 0), Count: 1},
+		},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1_z1": resources.CreateSchedulingMetadata(3, 3, 0, "z1"),
+			"n1_z2": resources.CreateSchedulingMetadata(4, 4, 0, "z2"),
+		}),
+		nodePriorityOrder:  []string{"n1_z1", "n1_z2"},
+		expectedDriverNode: "n1_z1",
+		willFit:            true,
+		expectedCounts:     map[string]int{"n1_z1": 1, "n1_z2": 1},
+		expectedProfiles:   []int{0, 1},
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.binpacker(
+				context.Background(),
+				test.driverResources,
+				test.executorGroups,
+				test.nodePriorityOrder,
+				test.nodePriorityOrder,
+				test.nodesSchedulingMetadata)
+			if p.HasCapacity != test.willFit {
+				t.Fatalf("mismatch in willFit, expected: %v, got: %v", test.willFit, p.HasCapacity)
+			}
+			if !test.willFit {
+				return
+			}
+			if p.DriverNode != test.expectedDriverNode {
+				t.Fatalf("mismatch in driver node, expected: %v, got: %v", test.expectedDriverNode, p.DriverNode)
+			}
+			resultCounts := map[string]int{}
+			for _, node := range p.ExecutorNodes {
+				resultCounts[node]++
+			}
+			if !reflect.DeepEqual(resultCounts, test.expectedCounts) {
+				t.Fatalf("executor nodes are not equal, expected: %v, got: %v", test.expectedCounts, resultCounts)
+			}
+			if !reflect.DeepEqual(p.ExecutorProfiles, test.expectedProfiles) {
+				t.Fatalf("executor profiles are not equal, expected: %v, got: %v", test.expectedProfiles, p.ExecutorProfiles)
+			}
+		})
+	}
+}
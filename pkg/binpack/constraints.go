@@ -0,0 +1,294 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/capacity"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+// ExecutorConstraints are per application constraints on how executors are placed, enforced by
+// WithExecutorConstraints. The zero value does not constrain placements.
+type ExecutorConstraints struct {
+	// MaxExecutorsPerNode limits how many executors are placed on a single node, unless it is not positive.
+	// With multiple executor groups, each group is limited separately.
+	MaxExecutorsPerNode int
+	// MinExecutorNodes is the minimum number of distinct nodes executors are placed on, unless it is not
+	// positive. Applications with fewer executors need one node per executor.
+	MinExecutorNodes int
+}
+
+// DriverPlacementPolicy constrains where the driver of an application is placed relative to its executors
+type DriverPlacementPolicy string
+
+const (
+	// DriverPlacementAny places the driver without regard to executors. This is the default.
+	DriverPlacementAny DriverPlacementPolicy = ""
+	// DriverPlacementIsolated places the driver on a node without executors of the same application, so that a
+	// node failure does not take down both the driver and some of its executors
+	DriverPlacementIsolated DriverPlacementPolicy = "isolated"
+	// DriverPlacementSameZone places all executors in the zone of the driver. Single-AZ strategies always do.
+	DriverPlacementSameZone DriverPlacementPolicy = "same-zone"
+	// DriverPlacementColocated places the driver on a node with at least one executor of the same application.
+	// Every driver candidate is tried with the driver node first in the executor priority order, and is only
+	// accepted when at least one executor is placed on it, pinning one there when the executors fit elsewhere.
+	// Applications without executors are not constrained.
+	DriverPlacementColocated DriverPlacementPolicy = "colocated"
+)
+
+// SparkBinPackWithDriverPlacement is like SparkBinPack, but places the driver relative to the executors according
+// to policy
+func SparkBinPackWithDriverPlacement(policy DriverPlacementPolicy, distributeExecutors GenericBinPackFunction) SparkBinPackFunction {
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+		executorGroups := []ExecutorGroup{{Resources: executorResources, Count: executorCount}}
+		placement, interrupted := sparkBinPackExecutorGroups(
+			ctx, policy, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata,
+			resources.NewLedger(nil), distributeExecutors)
+		if interrupted {
+			return TimedOutPackingResult()
+		}
+		if placement == nil {
+			return EmptyPackingResult()
+		}
+		return &PackingResult{
+			DriverNode:          placement.driverNode,
+			ExecutorNodes:       placement.executorNodes,
+			HasCapacity:         true,
+			PackingEfficiencies: computePackingEfficiencies(nodesSchedulingMetadata, placement.reserved),
+		}
+	})
+}
+
+// executorNodePriorityOrder narrows down and reorders the executor nodes for a driver on driverNodeName
+func (p DriverPlacementPolicy) executorNodePriorityOrder(
+	driverNodeName string,
+	executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) []string {
+	switch p {
+	case DriverPlacementIsolated:
+		return filterNodes(executorNodePriorityOrder, func(nodeName string) bool {
+			return nodeName != driverNodeName
+		})
+	case DriverPlacementSameZone:
+		driverSchedulingMetadata, ok := nodesSchedulingMetadata[driverNodeName]
+		if !ok {
+			// the driver does not fit on nodes without scheduling metadata, so it has no executor nodes
+			return nil
+		}
+		driverZone := driverSchedulingMetadata.ZoneLabel
+		return filterNodes(executorNodePriorityOrder, func(nodeName string) bool {
+			nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+			return ok && nodeSchedulingMetadata.ZoneLabel == driverZone
+		})
+	case DriverPlacementColocated:
+		if !containsNode(executorNodePriorityOrder, driverNodeName) {
+			return executorNodePriorityOrder
+		}
+		reordered := make([]string, 0, len(executorNodePriorityOrder))
+		reordered = append(reordered, driverNodeName)
+		return append(reordered, filterNodes(executorNodePriorityOrder, func(nodeName string) bool {
+			return nodeName != driverNodeName
+		})...)
+	default:
+		return executorNodePriorityOrder
+	}
+}
+
+// accepts returns true when executorNodes satisfy the policy for a driver on driverNodeName
+func (p DriverPlacementPolicy) accepts(driverNodeName string, executorNodes []string) bool {
+	if p != DriverPlacementColocated || len(executorNodes) == 0 {
+		return true
+	}
+	return containsNode(executorNodes, driverNodeName)
+}
+
+// withExecutorOnNode wraps distributeExecutors so that the first executor it is asked to place goes on nodeName, and
+// the remaining ones are placed by distributeExecutors
+func withExecutorOnNode(nodeName string, distributeExecutors GenericBinPackFunction) GenericBinPackFunction {
+	pinned := false
+	return func(
+		ctx context.Context,
+		executorResources *resources.Resources,
+		executorCount int,
+		nodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		reservedResources *resources.Ledger) ([]string, bool) {
+		if pinned || executorCount == 0 {
+			return distributeExecutors(ctx, executorResources, executorCount, nodePriorityOrder, nodesSchedulingMetadata, reservedResources)
+		}
+		pinned = true
+		if capacity.GetNodeCapacityFuncFromLedger(nodesSchedulingMetadata, reservedResources, executorResources)(nodeName) < 1 {
+			return nil, false
+		}
+		attempt := reservedResources.Snapshot()
+		reserveExecutors([]string{nodeName}, executorResources, attempt)
+		executorNodes, ok := distributeExecutors(
+			ctx, executorResources, executorCount-1, nodePriorityOrder, nodesSchedulingMetadata, attempt)
+		if !ok {
+			return nil, false
+		}
+		reservedResources.Merge(attempt)
+		return append([]string{nodeName}, executorNodes...), true
+	}
+}
+
+func filterNodes(nodeNames []string, keep func(nodeName string) bool) []string {
+	filtered := make([]string, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		if keep(nodeName) {
+			filtered = append(filtered, nodeName)
+		}
+	}
+	return filtered
+}
+
+func containsNode(nodeNames []string, nodeName string) bool {
+	for _, name := range nodeNames {
+		if name == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// WithExecutorConstraints wraps distributeExecutors so that the executors it places respect constraints.
+// MaxExecutorsPerNode is enforced by counting the executors placed on each node: executors placed beyond the limit
+// are placed again by distributeExecutors on the nodes that are not full yet, until all executors are placed.
+// When the executors end up on too few nodes, one executor is placed on each of the first MinExecutorNodes nodes
+// that fit one, in priority order, and distributeExecutors places the rest.
+//
+// Only executors placed through the returned function are constrained. Registered strategies are built with it by
+// Strategy.WithExecutorConstraints, e.g. to constrain the strategy returned by LookupStrategy.
+func WithExecutorConstraints(distributeExecutors GenericBinPackFunction, constraints ExecutorConstraints) GenericBinPackFunction {
+	return func(
+		ctx context.Context,
+		executorResources *resources.Resources,
+		executorCount int,
+		nodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		reservedResources *resources.Ledger) ([]string, bool) {
+		place := func(executorCount int, executorCounts map[string]int, reservedResources *resources.Ledger) ([]string, bool) {
+			return distributeWithMaxExecutorsPerNode(
+				ctx, executorResources, executorCount, constraints.MaxExecutorsPerNode, executorCounts, nodePriorityOrder,
+				nodesSchedulingMetadata, reservedResources, distributeExecutors)
+		}
+		minExecutorNodes := minInt(constraints.MinExecutorNodes, executorCount)
+		if minExecutorNodes <= 1 {
+			return place(executorCount, make(map[string]int), reservedResources)
+		}
+
+		attempt := reservedResources.Snapshot()
+		executorNodes, ok := place(executorCount, make(map[string]int), attempt)
+		if ok && len(distinctNodes(executorNodes)) < minExecutorNodes {
+			attempt = reservedResources.Snapshot()
+			executorNodes, ok = seedExecutorNodes(
+				executorResources, executorCount, minExecutorNodes, nodePriorityOrder, nodesSchedulingMetadata, attempt, place)
+		}
+		if !ok {
+			return nil, false
+		}
+		reservedResources.Merge(attempt)
+		return executorNodes, true
+	}
+}
+
+// seedExecutorNodes places one executor on each of the first minExecutorNodes nodes with room for one, and the
+// remaining executors with place
+func seedExecutorNodes(
+	executorResources *resources.Resources,
+	executorCount int,
+	minExecutorNodes int,
+	nodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	place func(executorCount int, executorCounts map[string]int, reservedResources *resources.Ledger) ([]string, bool)) ([]string, bool) {
+	nodeCapacity := capacity.GetNodeCapacityFuncFromLedger(nodesSchedulingMetadata, reservedResources, executorResources)
+	seedNodes := make([]string, 0, minExecutorNodes)
+	executorCounts := make(map[string]int, minExecutorNodes)
+	for _, nodeName := range distinctNodes(nodePriorityOrder) {
+		if len(seedNodes) == minExecutorNodes {
+			break
+		}
+		if nodeCapacity(nodeName) > 0 {
+			seedNodes = append(seedNodes, nodeName)
+			executorCounts[nodeName] = 1
+		}
+	}
+	if len(seedNodes) < minExecutorNodes {
+		return nil, false
+	}
+	reserveExecutors(seedNodes, executorResources, reservedResources)
+	if executorCount == len(seedNodes) {
+		return seedNodes, true
+	}
+	executorNodes, ok := place(executorCount-len(seedNodes), executorCounts, reservedResources)
+	if !ok {
+		return nil, false
+	}
+	return append(seedNodes, executorNodes...), true
+}
+
+// distributeWithMaxExecutorsPerNode places executorCount executors with distributeExecutors so that no node ends up
+// with more than maxExecutorsPerNode executors, unless it is not positive. executorCounts holds the executors
+// already placed on each node, and is updated with the executors placed. Every round places the executors that did
+// not fit under the limit in the previous one, on the nodes that are not full yet, and makes at least one more node
+// full when any executor is left, so the number of rounds is bound by the number of nodes.
+func distributeWithMaxExecutorsPerNode(
+	ctx context.Context,
+	executorResources *resources.Resources,
+	executorCount int,
+	maxExecutorsPerNode int,
+	executorCounts map[string]int,
+	nodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	distributeExecutors GenericBinPackFunction) ([]string, bool) {
+	if maxExecutorsPerNode <= 0 {
+		return distributeExecutors(ctx, executorResources, executorCount, nodePriorityOrder, nodesSchedulingMetadata, reservedResources)
+	}
+	attempt := reservedResources.Snapshot()
+	executorNodes := make([]string, 0, executorCount)
+	for len(executorNodes) < executorCount {
+		if isDone(ctx) {
+			return nil, false
+		}
+		notFull := filterNodes(nodePriorityOrder, func(nodeName string) bool {
+			return executorCounts[nodeName] < maxExecutorsPerNode
+		})
+		placed, ok := distributeExecutors(
+			ctx, executorResources, executorCount-len(executorNodes), notFull, nodesSchedulingMetadata, attempt.Snapshot())
+		if !ok {
+			return nil, false
+		}
+		accepted := make([]string, 0, len(placed))
+		for _, nodeName := range placed {
+			if executorCounts[nodeName] < maxExecutorsPerNode {
+				executorCounts[nodeName]++
+				accepted = append(accepted, nodeName)
+			}
+		}
+		reserveExecutors(accepted, executorResources, attempt)
+		executorNodes = append(executorNodes, accepted...)
+	}
+	reservedResources.Merge(attempt)
+	return executorNodes, true
+}
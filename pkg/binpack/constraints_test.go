@@ -0,0 +1,319 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestWithExecutorConstraints(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(16, 16, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(16, 16, 0, "zone1"),
+		"n3": resources.CreateSchedulingMetadata(16, 16, 0, "zone1"),
+		"n4": resources.CreateSchedulingMetadata(16, 16, 0, "zone1"),
+	})
+	nodePriorityOrder := []string{"n1", "n2", "n3", "n4"}
+
+	tests := []struct {
+		name             string
+		constraints      ExecutorConstraints
+		executorCount    int
+		willFit          bool
+		maxPerNode       int
+		minExecutorNodes int
+	}{{
+		name:          "limits executors per node",
+		constraints:   ExecutorConstraints{MaxExecutorsPerNode: 2},
+		executorCount: 7,
+		willFit:       true,
+		maxPerNode:    2,
+	}, {
+		name:          "does not fit when the limit leaves too little capacity",
+		constraints:   ExecutorConstraints{MaxExecutorsPerNode: 2},
+		executorCount: 9,
+		willFit:       false,
+	}, {
+		name:             "places executors on a minimum number of nodes",
+		constraints:      ExecutorConstraints{MinExecutorNodes: 3},
+		executorCount:    7,
+		willFit:          true,
+		maxPerNode:       7,
+		minExecutorNodes: 3,
+	}, {
+		name:             "needs one node per executor for small applications",
+		constraints:      ExecutorConstraints{MinExecutorNodes: 3},
+		executorCount:    2,
+		willFit:          true,
+		maxPerNode:       1,
+		minExecutorNodes: 2,
+	},
+	}
+
+	distributeExecutorFunctions := map[string]GenericBinPackFunction{
+		"tightly-pack":          tightlyPackExecutors,
+		"distribute-evenly":     distributeExecutorsEvenly,
+		"minimal-fragmentation": minimalFragmentation,
+	}
+	for distributeName, distributeExecutors := range distributeExecutorFunctions {
+		for _, test := range tests {
+			constrained := WithExecutorConstraints(distributeExecutors, test.constraints)
+			binpackers := map[string]SparkBinPackFunction{
+				"spark-bin-pack": func(
+					ctx context.Context,
+					driverResources, executorResources *resources.Resources,
+					executorCount int,
+					driverNodePriorityOrder, executorNodePriorityOrder []string,
+					nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+					return SparkBinPack(ctx, driverResources, executorResources, executorCount,
+						driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, constrained)
+				},
+				"single-az":   SingleAZ(constrained, nil),
+				"best-driver": BestDriver(constrained, 0, nil),
+			}
+			for binpackerName, binpacker := range binpackers {
+				t.Run(distributeName+"/"+binpackerName+"/"+test.name, func(t *testing.T) {
+					p := binpacker(
+						context.Background(),
+						resources.CreateResources(1, 1, 0),
+						resources.CreateResources(1, 1, 0),
+						test.executorCount,
+						nodePriorityOrder,
+						nodePriorityOrder,
+						nodesSchedulingMetadata)
+					if p.HasCapacity != test.willFit {
+						t.Fatalf("mismatch in willFit, expected: %v, got: %v", test.willFit, p.HasCapacity)
+					}
+					counts := make(map[string]int)
+					for _, n := range p.ExecutorNodes {
+						counts[n]++
+						if counts[n] > test.maxPerNode {
+							t.Fatalf("more than %v executors on %v: %v", test.maxPerNode, n, p.ExecutorNodes)
+						}
+					}
+					if len(counts) < test.minExecutorNodes {
+						t.Fatalf("executors on fewer than %v nodes: %v", test.minExecutorNodes, p.ExecutorNodes)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestWithExecutorConstraintsSeedsMinExecutorNodes(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"a": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"b": resources.CreateSchedulingMetadata(1, 1, 0, "zone1"),
+	})
+	distributeExecutors := WithExecutorConstraints(tightlyPackExecutors, ExecutorConstraints{MinExecutorNodes: 2})
+
+	executorNodes, ok := distributeExecutors(
+		context.Background(),
+		resources.CreateResources(1, 1, 0),
+		4,
+		[]string{"a", "b"},
+		nodesSchedulingMetadata,
+		resources.NewLedger(nil))
+	if !ok {
+		t.Fatalf("executors should fit")
+	}
+	counts := make(map[string]int)
+	for _, n := range executorNodes {
+		counts[n]++
+	}
+	expectedCounts := map[string]int{"a": 3, "b": 1}
+	if !reflect.DeepEqual(expectedCounts, counts) {
+		t.Fatalf("mismatch in executor counts, expected: %v, got: %v", expectedCounts, counts)
+	}
+}
+
+func TestWithExecutorConstraintsCountsZeroResourceExecutors(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"a": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"b": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"c": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+	})
+	for _, distributeExecutors := range []GenericBinPackFunction{tightlyPackExecutors, distributeExecutorsEvenly, minimalFragmentation} {
+		reservedResources := resources.NewLedger(nil)
+		constrained := WithExecutorConstraints(distributeExecutors, ExecutorConstraints{MaxExecutorsPerNode: 2})
+		executorNodes, ok := constrained(
+			context.Background(), resources.Zero(), 5, []string{"a", "b", "c"}, nodesSchedulingMetadata, reservedResources)
+		if !ok {
+			t.Fatalf("zero resource executors should fit")
+		}
+		counts := make(map[string]int)
+		for _, n := range executorNodes {
+			counts[n]++
+		}
+		if len(executorNodes) != 5 || counts["a"] > 2 || counts["b"] > 2 || counts["c"] > 2 {
+			t.Fatalf("executors per node not limited, got: %v", counts)
+		}
+
+		reservedResources = resources.NewLedger(nil)
+		_, ok = constrained(
+			context.Background(), resources.Zero(), 7, []string{"a", "b", "c"}, nodesSchedulingMetadata, reservedResources)
+		if ok {
+			t.Fatalf("more executors than the limit allows should not fit")
+		}
+		if nodes := reservedResources.ReservedNodes(); len(nodes) != 0 {
+			t.Fatalf("failed placement reserved resources on: %v", nodes)
+		}
+	}
+}
+
+func TestDriverPlacementPolicy(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(8, 8, 0, "zone2"),
+		"n3": resources.CreateSchedulingMetadata(2, 2, 0, "zone1"),
+	})
+	executorNodePriorityOrder := []string{"n2", "n1", "n3"}
+
+	tests := []struct {
+		name                    string
+		policy                  DriverPlacementPolicy
+		driverNodePriorityOrder []string
+		expectedDriverNode      string
+		expectedExecutorNodes   []string
+	}{{
+		name:                    "any",
+		policy:                  DriverPlacementAny,
+		driverNodePriorityOrder: []string{"n1", "n2", "n3"},
+		expectedDriverNode:      "n1",
+		expectedExecutorNodes:   []string{"n2", "n2"},
+	}, {
+		name:                    "isolated",
+		policy:                  DriverPlacementIsolated,
+		driverNodePriorityOrder: []string{"n2", "n1", "n3"},
+		expectedDriverNode:      "n2",
+		expectedExecutorNodes:   []string{"n1", "n1"},
+	}, {
+		name:                    "same zone",
+		policy:                  DriverPlacementSameZone,
+		driverNodePriorityOrder: []string{"n1", "n2", "n3"},
+		expectedDriverNode:      "n1",
+		expectedExecutorNodes:   []string{"n1", "n1"},
+	}, {
+		name:                    "same zone skips drivers without scheduling metadata",
+		policy:                  DriverPlacementSameZone,
+		driverNodePriorityOrder: []string{"gone", "n1"},
+		expectedDriverNode:      "n1",
+		expectedExecutorNodes:   []string{"n1", "n1"},
+	}, {
+		name:                    "colocated",
+		policy:                  DriverPlacementColocated,
+		driverNodePriorityOrder: []string{"n1", "n2", "n3"},
+		expectedDriverNode:      "n1",
+		expectedExecutorNodes:   []string{"n1", "n1"},
+	}, {
+		name:                    "colocated skips driver nodes without room for executors",
+		policy:                  DriverPlacementColocated,
+		driverNodePriorityOrder: []string{"n3", "n2", "n1"},
+		expectedDriverNode:      "n2",
+		expectedExecutorNodes:   []string{"n2", "n2"},
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := SparkBinPackWithDriverPlacement(test.policy, TightlyPackExecutors)(
+				context.Background(),
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(2, 2, 0),
+				2,
+				test.driverNodePriorityOrder,
+				executorNodePriorityOrder,
+				nodesSchedulingMetadata)
+			if !p.HasCapacity {
+				t.Fatalf("expected the application to fit")
+			}
+			if p.DriverNode != test.expectedDriverNode {
+				t.Fatalf("mismatch in driver node, expected: %v, got: %v", test.expectedDriverNode, p.DriverNode)
+			}
+			if !reflect.DeepEqual(test.expectedExecutorNodes, p.ExecutorNodes) {
+				t.Fatalf("mismatch in executor nodes, expected: %v, got: %v", test.expectedExecutorNodes, p.ExecutorNodes)
+			}
+		})
+	}
+}
+
+func TestDriverPlacementColocated(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"n3": resources.CreateSchedulingMetadata(3, 3, 0, "zone1"),
+	})
+	nodePriorityOrder := []string{"n1", "n2", "n3"}
+
+	for _, distributeExecutors := range []GenericBinPackFunction{TightlyPackExecutors, DistributeExecutorsEvenly, MinimalFragmentationExecutors} {
+		p := SparkBinPackWithDriverPlacement(DriverPlacementColocated, distributeExecutors)(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(2, 2, 0),
+			3,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata)
+		if !p.HasCapacity {
+			t.Fatalf("expected the application to fit")
+		}
+		driverNodeExecutors := filterNodes(p.ExecutorNodes, func(nodeName string) bool {
+			return nodeName == p.DriverNode
+		})
+		if len(driverNodeExecutors) == 0 {
+			t.Fatalf("driver node %v has no executors: %v", p.DriverNode, p.ExecutorNodes)
+		}
+	}
+
+	fullNodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"a": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"b": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+	})
+	for _, distributeExecutors := range []GenericBinPackFunction{TightlyPackExecutors, MinimalFragmentationExecutors} {
+		p := SparkBinPackWithDriverPlacement(DriverPlacementColocated, distributeExecutors)(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			4,
+			[]string{"a", "b"},
+			[]string{"a", "b"},
+			fullNodesSchedulingMetadata)
+		if !p.HasCapacity {
+			t.Fatalf("expected the application to fit with the driver next to executors that fill the nodes")
+		}
+		if p.DriverNode != "a" || len(p.ExecutorNodes) != 4 || !containsNode(p.ExecutorNodes, "a") {
+			t.Fatalf("expected the driver and at least one of 4 executors on a, got: %v %v", p.DriverNode, p.ExecutorNodes)
+		}
+	}
+
+	p := SparkBinPackWithDriverPlacement(DriverPlacementColocated, TightlyPackExecutors)(
+		context.Background(),
+		resources.CreateResources(1, 1, 0),
+		resources.CreateResources(1, 1, 0),
+		0,
+		[]string{"n3"},
+		nodePriorityOrder,
+		nodesSchedulingMetadata)
+	if !p.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if p.DriverNode != "n3" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "n3", p.DriverNode)
+	}
+}
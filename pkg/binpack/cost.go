@@ -0,0 +1,200 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+// CostLabels configures the node labels that cost aware packing reads from NodeSchedulingMetadata.AllLabels
+type CostLabels struct {
+	// PricePerHourKey is the key of the label holding the hourly price of a node, e.g. "0.192"
+	PricePerHourKey string
+	// DefaultPricePerHour is the price of nodes without a valid price label
+	DefaultPricePerHour float64
+	// CapacityTypeKey is the key of the label holding the capacity type of a node, e.g. karpenter.sh/capacity-type
+	CapacityTypeKey string
+	// SpotCapacityTypes are the values of the capacity type label of spot nodes, e.g. "spot"
+	SpotCapacityTypes []string
+}
+
+// PricePerHour returns the hourly price of a node
+func (c CostLabels) PricePerHour(nodeSchedulingMetadata *resources.NodeSchedulingMetadata) float64 {
+	value, ok := nodeSchedulingMetadata.AllLabels[c.PricePerHourKey]
+	if !ok {
+		return c.DefaultPricePerHour
+	}
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price < 0 || math.IsNaN(price) || math.IsInf(price, 0) {
+		return c.DefaultPricePerHour
+	}
+	return price
+}
+
+// IsSpot returns true if a node's capacity type is one of SpotCapacityTypes
+func (c CostLabels) IsSpot(nodeSchedulingMetadata *resources.NodeSchedulingMetadata) bool {
+	capacityType, ok := nodeSchedulingMetadata.AllLabels[c.CapacityTypeKey]
+	if !ok {
+		return false
+	}
+	for _, spotCapacityType := range c.SpotCapacityTypes {
+		if capacityType == spotCapacityType {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeCost returns a NodeCost that is the hourly price of a node, for use with NodeCostScorer
+func (c CostLabels) NodeCost() NodeCost {
+	return func(_ string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) float64 {
+		return c.PricePerHour(nodeSchedulingMetadata)
+	}
+}
+
+// NonSpotNodes returns a NodeFilter that excludes spot nodes
+func NonSpotNodes(costLabels CostLabels) NodeFilter {
+	return NodeFilter(func(_ string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) (bool, string) {
+		if costLabels.IsSpot(nodeSchedulingMetadata) {
+			return false, "node is a spot node"
+		}
+		return true, ""
+	})
+}
+
+// MarginalCostScorer returns a PlacementScorer that prefers placements with a lower marginal hourly cost. Using a
+// node that is idle costs its full price, since the node could otherwise be scaled down, while using a node that is
+// already partly used costs the fraction of its price that the application's resources on it take up, by dominant
+// resource.
+func MarginalCostScorer(costLabels CostLabels) PlacementScorer {
+	return PlacementScorerFunc(func(
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		application ApplicationResources,
+		packingResult *PackingResult) float64 {
+		dimensions := resources.DimensionsOf()
+		reservedByNode := application.reservedVectors(dimensions, packingResult)
+		cost := 0.0
+		for _, nodeName := range distinctNodes(usedNodes(packingResult)) {
+			if nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]; ok {
+				share := marginalShare(nodeSchedulingMetadata, dimensions, reservedByNode[nodeName])
+				cost += costLabels.PricePerHour(nodeSchedulingMetadata) * share
+			}
+		}
+		return -cost
+	})
+}
+
+// marginalShare is the fraction of a node a placement is charged for, 1 for idle nodes and the dominant share of
+// reserved, the application's resources on the node, otherwise
+func marginalShare(nodeSchedulingMetadata *resources.NodeSchedulingMetadata, dimensions resources.Dimensions, reserved resources.Vector) float64 {
+	if isIdle(nodeSchedulingMetadata) {
+		return 1
+	}
+	if reserved == nil {
+		return 0
+	}
+	schedulable := dimensions.Vector(nodeSchedulingMetadata.SchedulableResources)
+	share := 0.0
+	for i := range dimensions {
+		if schedulable[i] > 0 {
+			share = math.Max(share, float64(reserved[i])/float64(schedulable[i]))
+		}
+	}
+	return math.Min(share, 1)
+}
+
+func isIdle(nodeSchedulingMetadata *resources.NodeSchedulingMetadata) bool {
+	return !nodeSchedulingMetadata.SchedulableResources.GreaterThan(nodeSchedulingMetadata.AvailableResources)
+}
+
+// CostOrder returns nodePriorityOrder sorted so that cheaper placements come first: nodes that are already partly
+// used before idle nodes, and within each, nodes with a lower price per schedulable CPU first. Nodes with the same
+// rank keep their relative order, and nodes without scheduling metadata go last.
+func CostOrder(
+	costLabels CostLabels,
+	nodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) []string {
+
+	type rankedNode struct {
+		nodeName    string
+		missing     bool
+		idle        bool
+		pricePerCPU float64
+	}
+	rankedNodes := make([]rankedNode, 0, len(nodePriorityOrder))
+	for _, nodeName := range nodePriorityOrder {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if !ok {
+			rankedNodes = append(rankedNodes, rankedNode{nodeName: nodeName, missing: true})
+			continue
+		}
+		pricePerCPU := costLabels.PricePerHour(nodeSchedulingMetadata)
+		if cpu := nodeSchedulingMetadata.SchedulableResources.CPU.AsApproximateFloat64(); cpu > 0 {
+			pricePerCPU /= cpu
+		}
+		rankedNodes = append(rankedNodes, rankedNode{
+			nodeName:    nodeName,
+			idle:        isIdle(nodeSchedulingMetadata),
+			pricePerCPU: pricePerCPU,
+		})
+	}
+	sort.SliceStable(rankedNodes, func(i, j int) bool {
+		a, b := rankedNodes[i], rankedNodes[j]
+		if a.missing != b.missing {
+			return !a.missing
+		}
+		if a.idle != b.idle {
+			return !a.idle
+		}
+		return a.pricePerCPU < b.pricePerCPU
+	})
+	ordered := make([]string, 0, len(rankedNodes))
+	for _, node := range rankedNodes {
+		ordered = append(ordered, node.nodeName)
+	}
+	return ordered
+}
+
+// CostAware returns a SparkBinPackFunction that calls binpacker with both priority orders sorted by CostOrder, so
+// that order-following strategies such as TightlyPack fill cheap, partly used nodes first. When keepDriversOffSpot
+// is set, spot nodes are removed from the driver priority order and reported in PackingResult.FilteredNodes, since
+// losing the driver loses the whole application.
+func CostAware(costLabels CostLabels, keepDriversOffSpot bool, binpacker SparkBinPackFunction) SparkBinPackFunction {
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		driverNodes := CostOrder(costLabels, driverNodePriorityOrder, nodesSchedulingMetadata)
+		var filteredDriverNodes []FilteredNode
+		if keepDriversOffSpot {
+			driverNodes, filteredDriverNodes = FilterNodes(driverNodes, nodesSchedulingMetadata, NonSpotNodes(costLabels))
+		}
+		executorNodes := CostOrder(costLabels, executorNodePriorityOrder, nodesSchedulingMetadata)
+
+		packingResult := binpacker(ctx, driverResources, executorResources, executorCount, driverNodes, executorNodes, nodesSchedulingMetadata)
+		if len(filteredDriverNodes) > 0 {
+			packingResult.FilteredNodes = mergeFilteredNodes(packingResult.FilteredNodes, filteredDriverNodes)
+		}
+		return packingResult
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+var testCostLabels = CostLabels{
+	PricePerHourKey:     "price-per-hour",
+	DefaultPricePerHour: 1,
+	CapacityTypeKey:     "capacity-type",
+	SpotCapacityTypes:   []string{"spot"},
+}
+
+func costTestNodes() resources.NodeGroupSchedulingMetadata {
+	node := func(availableCPU int64, price, capacityType string) *resources.NodeSchedulingMetadata {
+		nodeSchedulingMetadata := resources.CreateSchedulingMetadataWithTotals(availableCPU, 8, availableCPU, 8, 0, 0, "zone1")
+		nodeSchedulingMetadata.AllLabels = map[string]string{"price-per-hour": price, "capacity-type": capacityType}
+		return nodeSchedulingMetadata
+	}
+	return resources.NodeGroupSchedulingMetadata{
+		"idle-cheap":      node(8, "0.1", "on-demand"),
+		"used-expensive":  node(4, "0.8", "on-demand"),
+		"used-cheap-spot": node(4, "0.2", "spot"),
+		"used-unpriced":   node(4, "unknown", "on-demand"),
+	}
+}
+
+func TestCostLabels(t *testing.T) {
+	nodesSchedulingMetadata := costTestNodes()
+	if price := testCostLabels.PricePerHour(nodesSchedulingMetadata["used-expensive"]); price != 0.8 {
+		t.Fatalf("mismatch in price, expected: %v, got: %v", 0.8, price)
+	}
+	// nodes without a valid price cost the default price
+	if price := testCostLabels.PricePerHour(nodesSchedulingMetadata["used-unpriced"]); price != 1.0 {
+		t.Fatalf("mismatch in price of an unpriced node, expected: %v, got: %v", 1.0, price)
+	}
+	if !testCostLabels.IsSpot(nodesSchedulingMetadata["used-cheap-spot"]) {
+		t.Fatalf("expected used-cheap-spot to be a spot node")
+	}
+	if testCostLabels.IsSpot(nodesSchedulingMetadata["idle-cheap"]) {
+		t.Fatalf("expected idle-cheap not to be a spot node")
+	}
+}
+
+func TestCostOrder(t *testing.T) {
+	ordered := CostOrder(
+		testCostLabels,
+		[]string{"missing", "idle-cheap", "used-unpriced", "used-expensive", "used-cheap-spot"},
+		costTestNodes())
+	expected := []string{"used-cheap-spot", "used-expensive", "used-unpriced", "idle-cheap", "missing"}
+	if !reflect.DeepEqual(expected, ordered) {
+		t.Fatalf("mismatch in order, expected: %v, got: %v", expected, ordered)
+	}
+}
+
+func TestCostAware(t *testing.T) {
+	nodesSchedulingMetadata := costTestNodes()
+	nodePriorityOrder := []string{"idle-cheap", "used-expensive", "used-cheap-spot"}
+	pack := func(keepDriversOffSpot bool) *PackingResult {
+		return CostAware(testCostLabels, keepDriversOffSpot, TightlyPack)(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			3,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata)
+	}
+
+	packingResult := pack(false)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if packingResult.DriverNode != "used-cheap-spot" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "used-cheap-spot", packingResult.DriverNode)
+	}
+	expectedExecutorNodes := []string{"used-cheap-spot", "used-cheap-spot", "used-cheap-spot"}
+	if !reflect.DeepEqual(expectedExecutorNodes, packingResult.ExecutorNodes) {
+		t.Fatalf("mismatch in executor nodes, expected: %v, got: %v", expectedExecutorNodes, packingResult.ExecutorNodes)
+	}
+	if len(packingResult.FilteredNodes) != 0 {
+		t.Fatalf("expected no filtered nodes, got: %v", packingResult.FilteredNodes)
+	}
+
+	packingResult = pack(true)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if packingResult.DriverNode != "used-expensive" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "used-expensive", packingResult.DriverNode)
+	}
+	if !reflect.DeepEqual(expectedExecutorNodes, packingResult.ExecutorNodes) {
+		t.Fatalf("mismatch in executor nodes, expected: %v, got: %v", expectedExecutorNodes, packingResult.ExecutorNodes)
+	}
+	expectedFilteredNodes := []FilteredNode{{NodeName: "used-cheap-spot", Reason: "node is a spot node"}}
+	if !reflect.DeepEqual(expectedFilteredNodes, packingResult.FilteredNodes) {
+		t.Fatalf("mismatch in filtered nodes, expected: %v, got: %v", expectedFilteredNodes, packingResult.FilteredNodes)
+	}
+}
+
+func TestMarginalCostScorer(t *testing.T) {
+	nodesSchedulingMetadata := costTestNodes()
+	placement := func(nodeName string) *PackingResult {
+		return &PackingResult{
+			DriverNode:    nodeName,
+			ExecutorNodes: []string{nodeName, nodeName, nodeName},
+			HasCapacity:   true,
+		}
+	}
+	scorer := MarginalCostScorer(testCostLabels)
+	application := ApplicationResources{
+		Driver:         resources.CreateResources(1, 2, 0),
+		ExecutorGroups: []ExecutorGroup{{Resources: resources.CreateResources(1, 0, 0), Count: 3}},
+	}
+
+	// the idle node costs its full price, partly used nodes the half of their capacity the placement takes up
+	if score := scorer.Score(nodesSchedulingMetadata, application, placement("idle-cheap")); math.Abs(score+0.1) > 1e-9 {
+		t.Fatalf("mismatch in score on idle-cheap, expected: %v, got: %v", -0.1, score)
+	}
+	if score := scorer.Score(nodesSchedulingMetadata, application, placement("used-expensive")); math.Abs(score+0.4) > 1e-9 {
+		t.Fatalf("mismatch in score on used-expensive, expected: %v, got: %v", -0.4, score)
+	}
+	if score := scorer.Score(nodesSchedulingMetadata, application, placement("used-cheap-spot")); math.Abs(score+0.1) > 1e-9 {
+		t.Fatalf("mismatch in score on used-cheap-spot, expected: %v, got: %v", -0.1, score)
+	}
+}
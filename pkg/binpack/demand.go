@@ -0,0 +1,198 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"sort"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/scaler/v1alpha2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// DemandPods names the pods of an application, so that demand units can be deduplicated against the pods they
+// are created for. Executor pod names are optional, as executors are usually requested before they exist.
+type DemandPods struct {
+	Namespace        string
+	DriverPodName    string
+	ExecutorPodNames []string
+}
+
+// DemandForApplication returns the demand that has to be fulfilled for an application that does not fit to be
+// packed by strategy on the given driver and executor nodes. Whatever part of the application already fits is left
+// out, so the demand holds a unit for the driver only when the driver does not fit on any driver node, and a unit
+// for the executors that do not fit once the driver is placed. The instance group of the demand is left to the
+// caller.
+//
+// Strategies that must place an application in a single zone, i.e. SingleZone strategies that can not fall back,
+// get a demand for the zone that is missing the fewest pods, with ties going to the alphabetically first zone. When
+// the application fits, the demand has no units. It returns an error when ctx is done before the demand is known,
+// as the pods that fit are unknown then.
+func DemandForApplication(
+	ctx context.Context,
+	driverResources, executorResources *resources.Resources,
+	executorCount int,
+	strategy Strategy,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	pods DemandPods) (v1alpha2.DemandSpec, error) {
+
+	if !strategy.SingleZone || strategy.CanFallBack {
+		units, err := missingDemandUnits(
+			ctx, driverResources, executorResources, executorCount, strategy.Function, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, pods)
+		if err != nil {
+			return v1alpha2.DemandSpec{}, err
+		}
+		return v1alpha2.DemandSpec{Units: units}, nil
+	}
+
+	driverZones, driverNodePriorityOrderByZone := groupNodesByZone(driverNodePriorityOrder, nodesSchedulingMetadata)
+	executorZones, executorNodePriorityOrderByZone := groupNodesByZone(executorNodePriorityOrder, nodesSchedulingMetadata)
+	zones := make([]string, 0, len(driverZones)+len(executorZones))
+	seenZones := make(map[string]bool, len(driverZones)+len(executorZones))
+	for _, zone := range append(driverZones, executorZones...) {
+		if !seenZones[zone] {
+			seenZones[zone] = true
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	var bestUnits []v1alpha2.DemandUnit
+	var bestZone v1alpha2.Zone
+	for _, zone := range zones {
+		units, err := missingDemandUnits(
+			ctx, driverResources, executorResources, executorCount, strategy.Function,
+			driverNodePriorityOrderByZone[zone], executorNodePriorityOrderByZone[zone], nodesSchedulingMetadata, pods)
+		if err != nil {
+			return v1alpha2.DemandSpec{}, err
+		}
+		if bestUnits == nil || demandUnitsCount(units) < demandUnitsCount(bestUnits) {
+			bestUnits, bestZone = units, v1alpha2.Zone(zone)
+		}
+	}
+	if bestUnits == nil {
+		// no nodes at all, nothing fits anywhere
+		units, err := missingDemandUnits(ctx, driverResources, executorResources, executorCount, strategy.Function, nil, nil, nodesSchedulingMetadata, pods)
+		if err != nil {
+			return v1alpha2.DemandSpec{}, err
+		}
+		bestUnits = units
+	}
+	demandSpec := v1alpha2.DemandSpec{
+		Units:                       bestUnits,
+		EnforceSingleZoneScheduling: true,
+	}
+	if bestZone != "" && len(bestUnits) > 0 {
+		demandSpec.Zone = &bestZone
+	}
+	return demandSpec, nil
+}
+
+// missingDemandUnits computes the units of the application that do not fit on the given nodes
+func missingDemandUnits(
+	ctx context.Context,
+	driverResources, executorResources *resources.Resources,
+	executorCount int,
+	binpacker SparkBinPackFunction,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	pods DemandPods) ([]v1alpha2.DemandUnit, error) {
+
+	driverFits := false
+	for _, nodeName := range driverNodePriorityOrder {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if ok && !driverResources.GreaterThan(nodeSchedulingMetadata.AvailableResources) {
+			driverFits = true
+			break
+		}
+	}
+
+	// when the driver does not fit, count how many executors fit on their own
+	packedDriverResources := driverResources
+	packedDriverNodePriorityOrder := driverNodePriorityOrder
+	if !driverFits {
+		packedDriverResources = resources.Zero()
+		packedDriverNodePriorityOrder = executorNodePriorityOrder
+	}
+	fittingExecutors := 0
+	packingResult, err := Elastic(binpacker)(
+		ctx, packedDriverResources, executorResources, 0, executorCount, packedDriverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+	if err != nil {
+		return nil, err
+	}
+	if packingResult.TimedOut {
+		return nil, werror.Error("timed out computing the demand of the application",
+			werror.SafeParam("executorCount", executorCount))
+	}
+	if packingResult.HasCapacity {
+		fittingExecutors = len(packingResult.ExecutorNodes) + len(packingResult.ExtraExecutorNodes)
+	}
+
+	units := make([]v1alpha2.DemandUnit, 0, 2)
+	if !driverFits {
+		units = append(units, v1alpha2.DemandUnit{
+			Resources:           demandResources(driverResources),
+			Count:               1,
+			PodNamesByNamespace: podNamesByNamespace(pods.Namespace, []string{pods.DriverPodName}),
+		})
+	}
+	if missingExecutors := executorCount - fittingExecutors; missingExecutors > 0 {
+		var executorPodNames []string
+		if len(pods.ExecutorPodNames) == executorCount {
+			executorPodNames = pods.ExecutorPodNames[fittingExecutors:]
+		}
+		units = append(units, v1alpha2.DemandUnit{
+			Resources:           demandResources(executorResources),
+			Count:               missingExecutors,
+			PodNamesByNamespace: podNamesByNamespace(pods.Namespace, executorPodNames),
+		})
+	}
+	return units, nil
+}
+
+// demandResources converts r to a ResourceList with every supported resource, along with the extended resources
+// of r
+func demandResources(r *resources.Resources) v1alpha2.ResourceList {
+	resourceList := make(v1alpha2.ResourceList, len(v1alpha2.AllSupportedResources)+len(r.Extended))
+	for _, name := range v1alpha2.AllSupportedResources {
+		resourceList[name] = r.Get(name)
+	}
+	for name, quantity := range r.Extended {
+		resourceList[name] = quantity.DeepCopy()
+	}
+	return resourceList
+}
+
+func podNamesByNamespace(namespace string, podNames []string) map[string][]string {
+	names := make([]string, 0, len(podNames))
+	for _, podName := range podNames {
+		if podName != "" {
+			names = append(names, podName)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return map[string][]string{namespace: names}
+}
+
+func demandUnitsCount(units []v1alpha2.DemandUnit) int {
+	count := 0
+	for _, unit := range units {
+		count += unit.Count
+	}
+	return count
+}
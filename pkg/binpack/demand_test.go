@@ -0,0 +1,188 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/scaler/v1alpha2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestDemandForApplication(t *testing.T) {
+	zone1 := v1alpha2.Zone("zone1")
+	driverResources := resources.CreateResources(2, 2, 0)
+	executorResources := resources.CreateResources(1, 1, 0)
+	demandResourceList := func(cpu, memory int64) v1alpha2.ResourceList {
+		return v1alpha2.ResourceList{
+			v1alpha2.ResourceCPU:       *resource.NewQuantity(cpu, resource.DecimalSI),
+			v1alpha2.ResourceMemory:    *resource.NewQuantity(memory, resource.BinarySI),
+			v1alpha2.ResourceNvidiaGPU: *resource.NewQuantity(0, resource.DecimalSI),
+		}
+	}
+	pods := DemandPods{
+		Namespace:        "namespace",
+		DriverPodName:    "driver",
+		ExecutorPodNames: []string{"executor-1", "executor-2", "executor-3", "executor-4"},
+	}
+
+	tests := []struct {
+		name                      string
+		strategy                  Strategy
+		nodesSchedulingMetadata   resources.NodeGroupSchedulingMetadata
+		driverNodePriorityOrder   []string
+		executorNodePriorityOrder []string
+		expectedDemand            v1alpha2.DemandSpec
+	}{{
+		name:     "application that fits needs no demand",
+		strategy: Strategy{Function: TightlyPack},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		},
+		driverNodePriorityOrder:   []string{"n1"},
+		executorNodePriorityOrder: []string{"n1"},
+		expectedDemand:            v1alpha2.DemandSpec{Units: []v1alpha2.DemandUnit{}},
+	}, {
+		name:     "only the executors that do not fit are demanded",
+		strategy: Strategy{Function: TightlyPack},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(3, 3, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(1, 1, 0, "zone2"),
+		},
+		driverNodePriorityOrder:   []string{"n1", "n2"},
+		executorNodePriorityOrder: []string{"n1", "n2"},
+		expectedDemand: v1alpha2.DemandSpec{Units: []v1alpha2.DemandUnit{{
+			Resources:           demandResourceList(1, 1),
+			Count:               2,
+			PodNamesByNamespace: map[string][]string{"namespace": {"executor-3", "executor-4"}},
+		}}},
+	}, {
+		name:     "driver that does not fit is demanded",
+		strategy: Strategy{Function: TightlyPack},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(1, 1, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(1, 1, 0, "zone2"),
+		},
+		driverNodePriorityOrder:   []string{"n1", "n2"},
+		executorNodePriorityOrder: []string{"n1", "n2"},
+		expectedDemand: v1alpha2.DemandSpec{Units: []v1alpha2.DemandUnit{{
+			Resources:           demandResourceList(2, 2),
+			Count:               1,
+			PodNamesByNamespace: map[string][]string{"namespace": {"driver"}},
+		}, {
+			Resources:           demandResourceList(1, 1),
+			Count:               2,
+			PodNamesByNamespace: map[string][]string{"namespace": {"executor-3", "executor-4"}},
+		}}},
+	}, {
+		name:     "single az strategy demands the zone missing the fewest pods",
+		strategy: Strategy{Function: SingleAZTightlyPack, SingleZone: true},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(5, 5, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(3, 3, 0, "zone2"),
+			"n3": resources.CreateSchedulingMetadata(2, 2, 0, "zone2"),
+		},
+		driverNodePriorityOrder:   []string{"n1", "n2", "n3"},
+		executorNodePriorityOrder: []string{"n1", "n2", "n3"},
+		expectedDemand: v1alpha2.DemandSpec{
+			Units: []v1alpha2.DemandUnit{{
+				Resources:           demandResourceList(1, 1),
+				Count:               1,
+				PodNamesByNamespace: map[string][]string{"namespace": {"executor-4"}},
+			}},
+			EnforceSingleZoneScheduling: true,
+			Zone:                        &zone1,
+		},
+	}, {
+		name:     "single zone strategy that can fall back does not enforce a zone",
+		strategy: Strategy{Function: AzAwareTightlyPack, SingleZone: true, CanFallBack: true},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(5, 5, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(3, 3, 0, "zone2"),
+			"n3": resources.CreateSchedulingMetadata(2, 2, 0, "zone2"),
+		},
+		driverNodePriorityOrder:   []string{"n1", "n2", "n3"},
+		executorNodePriorityOrder: []string{"n1", "n2", "n3"},
+		expectedDemand:            v1alpha2.DemandSpec{Units: []v1alpha2.DemandUnit{}},
+	}, {
+		name:     "driver that only fits outside of the driver nodes is demanded",
+		strategy: Strategy{Function: TightlyPack},
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(1, 1, 0, "zone1"),
+		},
+		driverNodePriorityOrder:   []string{"n2"},
+		executorNodePriorityOrder: []string{"n1"},
+		expectedDemand: v1alpha2.DemandSpec{Units: []v1alpha2.DemandUnit{{
+			Resources:           demandResourceList(2, 2),
+			Count:               1,
+			PodNamesByNamespace: map[string][]string{"namespace": {"driver"}},
+		}}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			demand, err := DemandForApplication(
+				context.Background(), driverResources, executorResources, 4, test.strategy,
+				test.driverNodePriorityOrder, test.executorNodePriorityOrder, test.nodesSchedulingMetadata, pods)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.expectedDemand, demand) {
+				t.Fatalf("mismatch in demand, expected: %v, got: %v", test.expectedDemand, demand)
+			}
+		})
+	}
+}
+
+func TestDemandForApplicationTimesOut(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, strategy := range []Strategy{{Function: TightlyPack}, {Function: SingleAZTightlyPack, SingleZone: true}} {
+		_, err := DemandForApplication(
+			ctx, resources.CreateResources(1, 1, 0), resources.CreateResources(1, 1, 0), 2, strategy,
+			[]string{"n1"}, []string{"n1"}, nodesSchedulingMetadata, DemandPods{})
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+}
+
+func TestDemandForApplicationIncludesExtendedResources(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+	}
+	executorResources := resources.CreateResources(1, 1, 0)
+	executorResources.Set("example.com/fpga", *resource.NewQuantity(1, resource.DecimalSI))
+
+	demand, err := DemandForApplication(
+		context.Background(), resources.CreateResources(1, 1, 0), executorResources, 2, Strategy{Function: TightlyPack},
+		[]string{"n1"}, []string{"n1"}, nodesSchedulingMetadata, DemandPods{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(demand.Units) != 1 {
+		t.Fatalf("expected a single demand unit, got: %v", demand.Units)
+	}
+	if fpga := demand.Units[0].Resources["example.com/fpga"]; fpga.Value() != 1 {
+		t.Fatalf("mismatch in demanded fpga, expected: %v, got: %v", 1, fpga.Value())
+	}
+}
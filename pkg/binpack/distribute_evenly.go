@@ -31,43 +31,58 @@ var DistributeEvenly = SparkBinPackFunction(func(
 	return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, distributeExecutorsEvenly)
 })
 
+// DistributeEvenlyMultiProfile is a MultiProfileSparkBinPackFunction that places the driver like DistributeEvenly and then
+// distributes each executor group in turn
+var DistributeEvenlyMultiProfile = MultiProfileSparkBinPackFunction(func(
+	ctx context.Context,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+	return SparkBinPackMultiProfile(ctx, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, distributeExecutorsEvenly)
+})
+
+// DistributeExecutorsEvenly is the GenericBinPackFunction DistributeEvenly uses to place executors
+var DistributeExecutorsEvenly = GenericBinPackFunction(distributeExecutorsEvenly)
+
 func distributeExecutorsEvenly(
 	ctx context.Context,
 	executorResources *resources.Resources,
 	executorCount int,
 	nodePriorityOrder []string,
 	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
-	reservedResources resources.NodeGroupResources) ([]string, bool) {
-	availableNodes := make(map[string]bool, len(nodePriorityOrder))
-	for _, name := range nodePriorityOrder {
-		availableNodes[name] = true
-	}
+	reservedResources *resources.Ledger) ([]string, bool) {
 	executorNodes := make([]string, 0, executorCount)
 	if executorCount == 0 {
 		return executorNodes, true
 	}
-	for len(availableNodes) > 0 {
+	nodeCapacity := executorCapacity(nodesSchedulingMetadata, reservedResources, executorResources)
+	remainingCapacities := make(map[string]int, len(nodePriorityOrder))
+	for _, n := range nodePriorityOrder {
+		if _, ok := remainingCapacities[n]; !ok {
+			remainingCapacities[n] = nodeCapacity(n)
+		}
+	}
+	for {
+		if isDone(ctx) {
+			return nil, false
+		}
+		placedInRound := false
 		for _, n := range nodePriorityOrder {
-			if _, ok := availableNodes[n]; !ok {
+			if remainingCapacities[n] == 0 {
+				// can not allocate a resource to this node
 				continue
 			}
-
-			if reservedResources[n] == nil {
-				reservedResources[n] = resources.Zero()
-			}
-			reservedResources[n].Add(executorResources)
-			nodeSchedulingMetadata, ok := nodesSchedulingMetadata[n]
-			if !ok || reservedResources[n].GreaterThan(nodeSchedulingMetadata.AvailableResources) {
-				// can not allocate a resource to this node
-				delete(availableNodes, n)
-				reservedResources[n].Sub(executorResources)
-			} else {
-				executorNodes = append(executorNodes, n)
-				if len(executorNodes) == executorCount {
-					return executorNodes, true
-				}
+			remainingCapacities[n]--
+			placedInRound = true
+			executorNodes = append(executorNodes, n)
+			if len(executorNodes) == executorCount {
+				reserveExecutors(executorNodes, executorResources, reservedResources)
+				return executorNodes, true
 			}
 		}
+		if !placedInRound {
+			return nil, false
+		}
 	}
-	return nil, false
 }
@@ -18,15 +18,18 @@ import (
 	"math"
 
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // AvgPackingEfficiency represents result packing efficiency per resource type for a group of nodes.
-// Computed as average packing efficiency over all node efficiencies.
+// Computed as average packing efficiency over all node efficiencies. Extended resources are averaged over
+// the nodes that have them, like GPU.
 type AvgPackingEfficiency struct {
-	CPU    float64
-	Memory float64
-	GPU    float64
-	Max    float64
+	CPU      float64
+	Memory   float64
+	GPU      float64
+	Extended map[corev1.ResourceName]float64
+	Max      float64
 }
 
 // LessThan compares two average packing efficiencies. For a single packing we take the highest of the
@@ -49,66 +52,124 @@ func WorstAvgPackingEfficiency() AvgPackingEfficiency {
 }
 
 // PackingEfficiency represents result packing efficiency per resource type for one node. Computed
-// as the total resources used divided by total capacity.
+// as the total resources used divided by total capacity. Extended only holds the extended resources
+// the node has.
 type PackingEfficiency struct {
 	NodeName string
 	CPU      float64
 	Memory   float64
 	GPU      float64
+	Extended map[corev1.ResourceName]float64
 }
 
-// Max returns the highest packing efficiency of all resources.
+// Max returns the highest packing efficiency of CPU, Memory and GPU. Extended resources are left out so that
+// resources applications do not ask for do not influence rankings, see MaxIncluding.
 func (p *PackingEfficiency) Max() float64 {
 	return math.Max(p.GPU, math.Max(p.CPU, p.Memory))
 }
 
+// MaxIncluding returns the highest packing efficiency of CPU, Memory, GPU and the given extended resources
+func (p *PackingEfficiency) MaxIncluding(extendedResourceNames ...corev1.ResourceName) float64 {
+	max := p.Max()
+	for _, name := range extendedResourceNames {
+		if efficiency, ok := p.Extended[name]; ok {
+			max = math.Max(max, efficiency)
+		}
+	}
+	return max
+}
+
 // ComputePackingEfficiencies calculates utilization for all provided nodes, given the new reservation.
 func ComputePackingEfficiencies(
 	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
 	reservedResources resources.NodeGroupResources) map[string]*PackingEfficiency {
+	return computePackingEfficiencies(nodeGroupSchedulingMetadata, resources.NewLedger(reservedResources))
+}
 
-	nodeEfficiencies := make(map[string]*PackingEfficiency, 0)
+func computePackingEfficiencies(
+	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger) map[string]*PackingEfficiency {
+
+	nodeEfficiencies := make(map[string]*PackingEfficiency, len(nodeGroupSchedulingMetadata))
+	vectors := &efficiencyVectors{}
 
 	for nodeName, nodeSchedulingMetadata := range nodeGroupSchedulingMetadata {
-		nodeEfficiencies[nodeName] = computePackingEfficiency(nodeName, *nodeSchedulingMetadata, reservedResources)
+		nodeEfficiencies[nodeName] = computePackingEfficiency(nodeName, nodeSchedulingMetadata, reservedResources, vectors)
 	}
 
 	return nodeEfficiencies
 }
 
+// efficiencyVectors are scratch vectors reused by computePackingEfficiency across nodes
+type efficiencyVectors struct {
+	schedulable, available, reserved resources.Vector
+}
+
+func (v *efficiencyVectors) resize(n int) {
+	if cap(v.schedulable) < n {
+		v.schedulable = make(resources.Vector, n)
+		v.available = make(resources.Vector, n)
+		v.reserved = make(resources.Vector, n)
+	}
+	v.schedulable, v.available, v.reserved = v.schedulable[:n], v.available[:n], v.reserved[:n]
+}
+
 func computePackingEfficiency(
 	nodeName string,
-	nodeSchedulingMetadata resources.NodeSchedulingMetadata,
-	reservedResources resources.NodeGroupResources) *PackingEfficiency {
-
-	nodeReservedResources := nodeSchedulingMetadata.SchedulableResources.Copy()
-	nodeReservedResources.Sub(nodeSchedulingMetadata.AvailableResources)
-	if reserved, ok := reservedResources[nodeName]; ok {
-		nodeReservedResources.Add(reserved)
+	nodeSchedulingMetadata *resources.NodeSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	vectors *efficiencyVectors) *PackingEfficiency {
+
+	dimensions := resources.DimensionsOf(nodeSchedulingMetadata.SchedulableResources)
+	vectors.resize(len(dimensions))
+	schedulable, available, reserved := vectors.schedulable, vectors.available, vectors.reserved
+	dimensions.SetVector(schedulable, nodeSchedulingMetadata.SchedulableResources)
+	dimensions.SetVector(available, nodeSchedulingMetadata.AvailableResources)
+	reservedResources.SetReservedVector(dimensions, nodeName, reserved)
+
+	// computed as floats because schedulable resources can be as large as math.MaxInt64, and rounded up to
+	// whole units like resource.Quantity.Value
+	value := func(milliValue float64, i int) float64 {
+		if i == 0 {
+			return math.Ceil(milliValue / 1000)
+		}
+		return milliValue
+	}
+	efficiency := func(i int) float64 {
+		used := value(float64(schedulable[i])-float64(available[i])+float64(reserved[i]), i)
+		total := value(float64(schedulable[i]), i)
+		if total == 0 {
+			total = 1
+		}
+		return used / total
 	}
-	nodeSchedulableResources := nodeSchedulingMetadata.SchedulableResources
 
 	// GPU treated differently because not every node has GPU
 	gpuEfficiency := 0.0
-	if nodeSchedulableResources.NvidiaGPU.Value() != 0 {
-		gpuEfficiency = float64(nodeReservedResources.NvidiaGPU.Value()) / float64(normalizeResource(nodeSchedulableResources.NvidiaGPU.Value()))
+	if schedulable[2] != 0 {
+		gpuEfficiency = efficiency(2)
+	}
+
+	var extendedEfficiencies map[corev1.ResourceName]float64
+	for i := 3; i < len(dimensions); i++ {
+		if schedulable[i] == 0 {
+			continue
+		}
+		if extendedEfficiencies == nil {
+			extendedEfficiencies = make(map[corev1.ResourceName]float64, len(dimensions)-3)
+		}
+		extendedEfficiencies[dimensions[i]] = efficiency(i)
 	}
 
 	return &PackingEfficiency{
 		NodeName: nodeName,
-		CPU:      float64(nodeReservedResources.CPU.Value()) / float64(normalizeResource(nodeSchedulableResources.CPU.Value())),
-		Memory:   float64(nodeReservedResources.Memory.Value()) / float64(normalizeResource(nodeSchedulableResources.Memory.Value())),
+		CPU:      efficiency(0),
+		Memory:   efficiency(1),
 		GPU:      gpuEfficiency,
+		Extended: extendedEfficiencies,
 	}
 }
 
-func normalizeResource(resourceValue int64) int64 {
-	if resourceValue == 0 {
-		return 1
-	}
-	return resourceValue
-}
-
 // ComputeAvgPackingEfficiency calculate average packing efficiency, given packing efficiencies for
 // individual nodes.
 func ComputeAvgPackingEfficiency(
@@ -121,6 +182,8 @@ func ComputeAvgPackingEfficiency(
 
 	var cpuSum, gpuSum, memorySum, maxSum float64
 	nodesWithGPU := 0
+	extendedSums := make(map[corev1.ResourceName]float64)
+	nodesWithExtended := make(map[corev1.ResourceName]int)
 
 	for _, packingEfficiency := range packingEfficiencies {
 		nodeName := packingEfficiency.NodeName
@@ -134,7 +197,12 @@ func ComputeAvgPackingEfficiency(
 			nodesWithGPU++
 		}
 
-		maxSum += math.Max(packingEfficiency.GPU, math.Max(packingEfficiency.CPU, packingEfficiency.Memory))
+		for name, efficiency := range packingEfficiency.Extended {
+			extendedSums[name] += efficiency
+			nodesWithExtended[name]++
+		}
+
+		maxSum += packingEfficiency.Max()
 	}
 
 	length := math.Max(float64(len(packingEfficiencies)), 1)
@@ -145,11 +213,20 @@ func ComputeAvgPackingEfficiency(
 		gpuEfficiency = gpuSum / float64(nodesWithGPU)
 	}
 
+	var extendedEfficiencies map[corev1.ResourceName]float64
+	if len(extendedSums) > 0 {
+		extendedEfficiencies = make(map[corev1.ResourceName]float64, len(extendedSums))
+		for name, sum := range extendedSums {
+			extendedEfficiencies[name] = sum / float64(nodesWithExtended[name])
+		}
+	}
+
 	avgEfficiency := AvgPackingEfficiency{
-		CPU:    cpuSum / length,
-		Memory: memorySum / length,
-		GPU:    gpuEfficiency,
-		Max:    maxSum / length,
+		CPU:      cpuSum / length,
+		Memory:   memorySum / length,
+		GPU:      gpuEfficiency,
+		Extended: extendedEfficiencies,
+		Max:      maxSum / length,
 	}
 
 	return avgEfficiency
@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
@@ -48,8 +49,9 @@ func TestSinglePackingEfficiency(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			p := computePackingEfficiency(
 				test.nodeName,
-				test.nodesSchedulingMetadata,
-				test.reservedResources)
+				&test.nodesSchedulingMetadata,
+				resources.NewLedger(test.reservedResources),
+				&efficiencyVectors{})
 
 			expectedMax := math.Max(test.expectedGPUEfficiency, math.Max(test.expectedCPUEfficiency, test.expectedMemoryEfficiency))
 
@@ -185,3 +187,45 @@ func createReservedResources(nodeNames []string, nodeResources []*resources.Reso
 
 	return reserved
 }
+
+func TestExtendedResourcePackingEfficiency(t *testing.T) {
+	ephemeralStorage := func(quantity string) map[corev1.ResourceName]resource.Quantity {
+		return map[corev1.ResourceName]resource.Quantity{corev1.ResourceEphemeralStorage: resource.MustParse(quantity)}
+	}
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone1"),
+	})
+	nodesSchedulingMetadata["n1"].AvailableResources.Extended = ephemeralStorage("100Gi")
+	nodesSchedulingMetadata["n1"].SchedulableResources.Extended = ephemeralStorage("100Gi")
+
+	reserved := createNodeReservedResources("n1", "1", "1", "0")
+	reserved["n1"].Extended = ephemeralStorage("80Gi")
+
+	efficiencies := ComputePackingEfficiencies(nodesSchedulingMetadata, reserved)
+	if math.Abs(0.8-efficiencies["n1"].Extended[corev1.ResourceEphemeralStorage]) > CmpTolerance {
+		t.Fatalf("mismatch in ephemeral storage efficiency, expected: %v, got: %v", 0.8, efficiencies["n1"].Extended)
+	}
+	if math.Abs(0.1-efficiencies["n1"].Max()) > CmpTolerance {
+		t.Fatalf("mismatch in max efficiency, expected: %v, got: %v", 0.1, efficiencies["n1"].Max())
+	}
+	if math.Abs(0.8-efficiencies["n1"].MaxIncluding(corev1.ResourceEphemeralStorage)) > CmpTolerance {
+		t.Fatalf("mismatch in max efficiency, expected: %v, got: %v", 0.8, efficiencies["n1"].MaxIncluding(corev1.ResourceEphemeralStorage))
+	}
+	if _, ok := efficiencies["n2"].Extended[corev1.ResourceEphemeralStorage]; ok {
+		t.Fatalf("expected no ephemeral storage efficiency for a node without ephemeral storage")
+	}
+
+	avgEfficiency := ComputeAvgPackingEfficiency(nodesSchedulingMetadata, []*PackingEfficiency{efficiencies["n1"], efficiencies["n2"]})
+	if math.Abs(0.8-avgEfficiency.Extended[corev1.ResourceEphemeralStorage]) > CmpTolerance {
+		t.Fatalf("mismatch in average ephemeral storage efficiency, expected: %v, got: %v", 0.8, avgEfficiency.Extended)
+	}
+
+	packingResult := &PackingResult{DriverNode: "n1", PackingEfficiencies: efficiencies}
+	if score := AvgPackingEfficiencyScorer.Score(nodesSchedulingMetadata, ApplicationResources{}, packingResult); math.Abs(0.1-score) > CmpTolerance {
+		t.Fatalf("mismatch in score, expected: %v, got: %v", 0.1, score)
+	}
+	if score := ExtendedAvgPackingEfficiencyScorer(corev1.ResourceEphemeralStorage).Score(nodesSchedulingMetadata, ApplicationResources{}, packingResult); math.Abs(0.8-score) > CmpTolerance {
+		t.Fatalf("mismatch in score, expected: %v, got: %v", 0.8, score)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// ElasticSparkBinPackFunction is a function type for assigning nodes to spark drivers and a variable number of
+// executors, as used by applications with dynamic allocation enabled. It returns an error when the executor counts
+// are not a valid range.
+type ElasticSparkBinPackFunction func(
+	ctx context.Context,
+	driverResources, executorResources *resources.Resources,
+	minExecutorCount, maxExecutorCount int,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) (*PackingResult, error)
+
+// Elastic turns a SparkBinPackFunction into an ElasticSparkBinPackFunction. The returned function guarantees
+// minExecutorCount executors, which are returned in ExecutorNodes, and places as many additional executors as fit,
+// up to maxExecutorCount in total. The additional executors are returned separately in ExtraExecutorNodes so that
+// callers can create soft reservations for them.
+//
+// The largest executor count that fits is found with a binary search, which assumes that if binpacker can fit n
+// executors it can also fit any count below n. This holds for the strategies of this package, e.g. TightlyPack,
+// DistributeEvenly, MinimalFragmentation and their single-AZ and zone aware variants. When ctx is done during the
+// search, see BestEffortOnTimeout.
+func Elastic(binpacker SparkBinPackFunction, options ...SearchOption) ElasticSparkBinPackFunction {
+	searchOptions := newSearchOptions(options)
+	return ElasticSparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		minExecutorCount, maxExecutorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) (*PackingResult, error) {
+		if minExecutorCount < 0 || maxExecutorCount < minExecutorCount {
+			return nil, werror.Error("invalid elastic executor counts",
+				werror.SafeParam("minExecutorCount", minExecutorCount),
+				werror.SafeParam("maxExecutorCount", maxExecutorCount))
+		}
+
+		pack := func(executorCount int) *PackingResult {
+			return binpacker(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+		}
+
+		bestResult := pack(minExecutorCount)
+		if !bestResult.HasCapacity {
+			return bestResult, nil
+		}
+
+		if maxExecutorCount > minExecutorCount {
+			packingResult := pack(maxExecutorCount)
+			if packingResult.TimedOut {
+				return searchOptions.timedOut(splitExtraExecutors(bestResult, minExecutorCount)), nil
+			}
+			if packingResult.HasCapacity {
+				bestResult = packingResult
+			} else {
+				// invariant: lo executors fit, hi executors do not
+				lo, hi := minExecutorCount, maxExecutorCount
+				for hi-lo > 1 {
+					mid := lo + (hi-lo)/2
+					packingResult := pack(mid)
+					if packingResult.TimedOut {
+						return searchOptions.timedOut(splitExtraExecutors(bestResult, minExecutorCount)), nil
+					}
+					if packingResult.HasCapacity {
+						bestResult = packingResult
+						lo = mid
+					} else {
+						hi = mid
+					}
+				}
+			}
+		}
+
+		return splitExtraExecutors(bestResult, minExecutorCount), nil
+	})
+}
+
+func splitExtraExecutors(packingResult *PackingResult, minExecutorCount int) *PackingResult {
+	executorNodes := packingResult.ExecutorNodes
+	splitResult := *packingResult
+	splitResult.ExecutorNodes = executorNodes[:minExecutorCount:minExecutorCount]
+	splitResult.ExtraExecutorNodes = executorNodes[minExecutorCount:]
+	return &splitResult
+}
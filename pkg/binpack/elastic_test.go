@@ -0,0 +1,170 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestElastic(t *testing.T) {
+	tests := []struct {
+		name                    string
+		binpacker               SparkBinPackFunction
+		driverResources         *resources.Resources
+		executorResources       *resources.Resources
+		minExecutors            int
+		maxExecutors            int
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata
+		nodePriorityOrder       []string
+		willFit                 bool
+		expectedExtraExecutors  int
+	}{{
+		name:              "places all executors when the maximum fits",
+		binpacker:         TightlyPack,
+		driverResources:   resources.CreateResources(1, 1, 0),
+		executorResources: resources.CreateResources(1, 1, 0),
+		minExecutors:      2,
+		maxExecutors:      5,
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		}),
+		nodePriorityOrder:      []string{"n1", "n2"},
+		willFit:                true,
+		expectedExtraExecutors: 3,
+	}, {
+		name:              "places as many extra executors as fit",
+		binpacker:         DistributeEvenly,
+		driverResources:   resources.CreateResources(1, 1, 0),
+		executorResources: resources.CreateResources(1, 1, 0),
+		minExecutors:      2,
+		maxExecutors:      20,
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		}),
+		nodePriorityOrder:      []string{"n1", "n2"},
+		willFit:                true,
+		expectedExtraExecutors: 5,
+	}, {
+		name:              "works with minimal fragmentation",
+		binpacker:         MinimalFragmentation,
+		driverResources:   resources.CreateResources(1, 1, 0),
+		executorResources: resources.CreateResources(2, 2, 0),
+		minExecutors:      1,
+		maxExecutors:      10,
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(5, 5, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(6, 6, 0, "zone1"),
+		}),
+		nodePriorityOrder:      []string{"n1", "n2"},
+		willFit:                true,
+		expectedExtraExecutors: 4,
+	}, {
+		name:              "works with single az minimal fragmentation",
+		binpacker:         SingleAZMinimalFragmentation,
+		driverResources:   resources.CreateResources(1, 1, 0),
+		executorResources: resources.CreateResources(1, 1, 0),
+		minExecutors:      1,
+		maxExecutors:      10,
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1_z1": resources.CreateSchedulingMetadata(5, 5, 0, "z1"),
+			"n1_z2": resources.CreateSchedulingMetadata(4, 4, 0, "z2"),
+		}),
+		nodePriorityOrder:      []string{"n1_z1", "n1_z2"},
+		willFit:                true,
+		expectedExtraExecutors: 3,
+	}, {
+		name:              "works with az aware tightly pack",
+		binpacker:         AzAwareTightlyPack,
+		driverResources:   resources.CreateResources(1, 1, 0),
+		executorResources: resources.CreateResources(1, 1, 0),
+		minExecutors:      1,
+		maxExecutors:      10,
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1_z1": resources.CreateSchedulingMetadata(3, 3, 0, "z1"),
+			"n1_z2": resources.CreateSchedulingMetadata(4, 4, 0, "z2"),
+		}),
+		nodePriorityOrder:      []string{"n1_z1", "n1_z2"},
+		willFit:                true,
+		expectedExtraExecutors: 5,
+	}, {
+		name:              "does not fit when the minimum does not fit",
+		binpacker:         TightlyPack,
+		driverResources:   resources.CreateResources(1, 1, 0),
+		executorResources: resources.CreateResources(1, 1, 0),
+		minExecutors:      8,
+		maxExecutors:      10,
+		nodesSchedulingMetadata: resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+			"n2": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		}),
+		nodePriorityOrder: []string{"n1", "n2"},
+		willFit:           false,
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := Elastic(test.binpacker)(
+				context.Background(),
+				test.driverResources,
+				test.executorResources,
+				test.minExecutors,
+				test.maxExecutors,
+				test.nodePriorityOrder,
+				test.nodePriorityOrder,
+				test.nodesSchedulingMetadata)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.HasCapacity != test.willFit {
+				t.Fatalf("mismatch in willFit, expected: %v, got: %v", test.willFit, p.HasCapacity)
+			}
+			if !test.willFit {
+				return
+			}
+			if len(p.ExecutorNodes) != test.minExecutors {
+				t.Fatalf("mismatch in guaranteed executors, expected: %v, got: %v", test.minExecutors, len(p.ExecutorNodes))
+			}
+			if len(p.ExtraExecutorNodes) != test.expectedExtraExecutors {
+				t.Fatalf("mismatch in extra executors, expected: %v, got: %v", test.expectedExtraExecutors, len(p.ExtraExecutorNodes))
+			}
+		})
+	}
+}
+
+func TestElasticRejectsInvalidExecutorCounts(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+	})
+	for _, counts := range [][2]int{{3, 2}, {-1, 2}} {
+		_, err := Elastic(TightlyPack)(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			counts[0],
+			counts[1],
+			[]string{"n1"},
+			[]string{"n1"},
+			nodesSchedulingMetadata)
+		if err == nil {
+			t.Fatalf("expected an error for min %v and max %v executors", counts[0], counts[1])
+		}
+	}
+}
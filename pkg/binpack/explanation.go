@@ -0,0 +1,186 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/capacity"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PackingExplanation describes why an application could not be packed. Executor capacities are capped at the
+// requested executor count.
+type PackingExplanation struct {
+	// RejectedDriverNodes lists every driver candidate in priority order
+	RejectedDriverNodes []RejectedDriverNode
+	// ExecutorCapacityByNode is how many executors fit on each executor candidate, ignoring the driver
+	ExecutorCapacityByNode map[string]int
+	// ExecutorCapacityByZone is how many executors fit in each zone, ignoring the driver
+	ExecutorCapacityByZone map[string]int
+	// ExecutorShortfall is how many executors are missing for the application to fit, when the driver is
+	// placed on the candidate that leaves the most room for executors. It is zero when there is enough total
+	// capacity but the strategy could not use it, e.g. because it does not fit into a single zone.
+	ExecutorShortfall int
+	// DriverFits is false when the driver does not fit on any of the driver candidates
+	DriverFits bool
+}
+
+// RejectedDriverNode is a driver candidate that did not lead to a successful packing
+type RejectedDriverNode struct {
+	NodeName string
+	Zone     string
+	// LimitingResources are the resources the driver does not fit in. When the driver fits but executors do not,
+	// they are the resources that limit how many executors fit on the executor candidates that can not hold all
+	// executors, once the driver is placed on this node.
+	LimitingResources []corev1.ResourceName
+	// ExecutorCapacity is how many executors fit once the driver is placed on this node, zero when the
+	// driver does not fit
+	ExecutorCapacity int
+	// NoSchedulingMetadata is true when the node has no scheduling metadata, so nothing fits on it. Zone and
+	// LimitingResources are then empty.
+	NoSchedulingMetadata bool
+}
+
+// WithExplanation returns a SparkBinPackFunction that calls binpacker and, when the application does not fit,
+// populates PackingResult.Explanation with ExplainPackingFailure. Explanations are not computed by any strategy on
+// its own, so that searching strategies such as Elastic or SingleAZ do not pay for them on every attempt. Wrap only
+// the outermost SparkBinPackFunction.
+func WithExplanation(binpacker SparkBinPackFunction) SparkBinPackFunction {
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		packingResult := binpacker(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+		if !packingResult.HasCapacity && !packingResult.TimedOut {
+			packingResult.Explanation = ExplainPackingFailure(
+				driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+		}
+		return packingResult
+	})
+}
+
+// ExplainPackingFailure computes a PackingExplanation for an application that could not be packed
+func ExplainPackingFailure(
+	driverResources, executorResources *resources.Resources,
+	executorCount int,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingExplanation {
+
+	explanation := &PackingExplanation{
+		RejectedDriverNodes:    make([]RejectedDriverNode, 0, len(driverNodePriorityOrder)),
+		ExecutorCapacityByNode: make(map[string]int, len(executorNodePriorityOrder)),
+		ExecutorCapacityByZone: make(map[string]int),
+	}
+
+	// nodes listed more than once only hold their executors once
+	nodeCapacities := capacity.GetNodeCapacities(distinctNodes(executorNodePriorityOrder), nodesSchedulingMetadata, nil, executorResources)
+	totalCapacity := 0
+	// how many executor candidates that can not hold all executors are limited by each resource
+	limitingResourceCounts := make(map[corev1.ResourceName]int)
+	limitingResourcesByNode := make(map[string][]corev1.ResourceName)
+	for _, nodeCapacity := range nodeCapacities {
+		nodeSchedulingMetadata := nodesSchedulingMetadata[nodeCapacity.NodeName]
+		c := minInt(nodeCapacity.Capacity, executorCount)
+		explanation.ExecutorCapacityByNode[nodeCapacity.NodeName] = c
+		explanation.ExecutorCapacityByZone[nodeSchedulingMetadata.ZoneLabel] += c
+		totalCapacity += c
+		if c < executorCount {
+			limitingResources := capacity.GetLimitingResources(nodeSchedulingMetadata.AvailableResources, resources.Zero(), executorResources)
+			limitingResourcesByNode[nodeCapacity.NodeName] = limitingResources
+			addCounts(limitingResourceCounts, limitingResources, 1)
+		}
+	}
+	for zone, c := range explanation.ExecutorCapacityByZone {
+		explanation.ExecutorCapacityByZone[zone] = minInt(c, executorCount)
+	}
+
+	bestExecutorCapacity := 0
+	for _, driverNodeName := range driverNodePriorityOrder {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[driverNodeName]
+		if !ok {
+			explanation.RejectedDriverNodes = append(explanation.RejectedDriverNodes, RejectedDriverNode{
+				NodeName:             driverNodeName,
+				NoSchedulingMetadata: true,
+			})
+			continue
+		}
+		rejected := RejectedDriverNode{
+			NodeName:          driverNodeName,
+			Zone:              nodeSchedulingMetadata.ZoneLabel,
+			LimitingResources: driverResources.ExceededResources(nodeSchedulingMetadata.AvailableResources),
+		}
+		if len(rejected.LimitingResources) == 0 {
+			explanation.DriverFits = true
+			executorCapacity := totalCapacity
+			counts := limitingResourceCounts
+			if c, ok := explanation.ExecutorCapacityByNode[driverNodeName]; ok {
+				withDriver := minInt(capacity.GetNodeCapacity(nodeSchedulingMetadata.AvailableResources, driverResources, executorResources), executorCount)
+				executorCapacity += withDriver - c
+				// the driver node is limited by the resources left next to the driver instead
+				counts = make(map[corev1.ResourceName]int, len(limitingResourceCounts))
+				for name, count := range limitingResourceCounts {
+					counts[name] = count
+				}
+				addCounts(counts, limitingResourcesByNode[driverNodeName], -1)
+				if withDriver < executorCount {
+					addCounts(counts, capacity.GetLimitingResources(nodeSchedulingMetadata.AvailableResources, driverResources, executorResources), 1)
+				}
+			}
+			rejected.LimitingResources = positiveCountResources(resources.DimensionsOf(executorResources), counts)
+			rejected.ExecutorCapacity = minInt(executorCapacity, executorCount)
+			if rejected.ExecutorCapacity > bestExecutorCapacity {
+				bestExecutorCapacity = rejected.ExecutorCapacity
+			}
+		}
+		explanation.RejectedDriverNodes = append(explanation.RejectedDriverNodes, rejected)
+	}
+
+	if !explanation.DriverFits {
+		bestExecutorCapacity = minInt(totalCapacity, executorCount)
+	}
+	if bestExecutorCapacity < executorCount {
+		explanation.ExecutorShortfall = executorCount - bestExecutorCapacity
+	}
+	return explanation
+}
+
+func addCounts(counts map[corev1.ResourceName]int, names []corev1.ResourceName, delta int) {
+	for _, name := range names {
+		counts[name] += delta
+	}
+}
+
+// positiveCountResources returns the names with a positive count, in the order of dimensions
+func positiveCountResources(dimensions resources.Dimensions, counts map[corev1.ResourceName]int) []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0)
+	for _, name := range dimensions {
+		if counts[name] > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
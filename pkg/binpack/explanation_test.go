@@ -0,0 +1,175 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestExplanationOnFailure(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1_z1": resources.CreateSchedulingMetadata(8, 2, 0, "z1"),
+		"n2_z1": resources.CreateSchedulingMetadata(4, 8, 0, "z1"),
+		"n1_z2": resources.CreateSchedulingMetadata(4, 8, 0, "z2"),
+	})
+	nodePriorityOrder := []string{"n1_z1", "n2_z1", "n1_z2"}
+
+	p := WithExplanation(TightlyPack)(
+		context.Background(),
+		resources.CreateResources(2, 4, 1),
+		resources.CreateResources(2, 2, 0),
+		8,
+		nodePriorityOrder,
+		nodePriorityOrder,
+		nodesSchedulingMetadata)
+	if p.HasCapacity {
+		t.Fatalf("expected the application not to fit")
+	}
+	if p.Explanation == nil {
+		t.Fatalf("expected an explanation")
+	}
+
+	explanation := p.Explanation
+	if explanation.DriverFits {
+		t.Fatalf("expected the driver not to fit")
+	}
+	expectedRejectedDriverNodes := []RejectedDriverNode{
+		{NodeName: "n1_z1", Zone: "z1", LimitingResources: []corev1.ResourceName{v1beta2.ResourceMemory, v1beta2.ResourceNvidiaGPU}},
+		{NodeName: "n2_z1", Zone: "z1", LimitingResources: []corev1.ResourceName{v1beta2.ResourceNvidiaGPU}},
+		{NodeName: "n1_z2", Zone: "z2", LimitingResources: []corev1.ResourceName{v1beta2.ResourceNvidiaGPU}},
+	}
+	if !reflect.DeepEqual(expectedRejectedDriverNodes, explanation.RejectedDriverNodes) {
+		t.Fatalf("mismatch in rejected driver nodes, expected: %v, got: %v", expectedRejectedDriverNodes, explanation.RejectedDriverNodes)
+	}
+	expectedExecutorCapacityByNode := map[string]int{"n1_z1": 1, "n2_z1": 2, "n1_z2": 2}
+	if !reflect.DeepEqual(expectedExecutorCapacityByNode, explanation.ExecutorCapacityByNode) {
+		t.Fatalf("mismatch in executor capacity by node, expected: %v, got: %v", expectedExecutorCapacityByNode, explanation.ExecutorCapacityByNode)
+	}
+	expectedExecutorCapacityByZone := map[string]int{"z1": 3, "z2": 2}
+	if !reflect.DeepEqual(expectedExecutorCapacityByZone, explanation.ExecutorCapacityByZone) {
+		t.Fatalf("mismatch in executor capacity by zone, expected: %v, got: %v", expectedExecutorCapacityByZone, explanation.ExecutorCapacityByZone)
+	}
+	if explanation.ExecutorShortfall != 3 {
+		t.Fatalf("mismatch in executor shortfall, expected: %v, got: %v", 3, explanation.ExecutorShortfall)
+	}
+}
+
+func TestExplanationListsDriverNodesWithoutSchedulingMetadata(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(1, 1, 0, "z1"),
+	})
+
+	explanation := ExplainPackingFailure(
+		resources.CreateResources(2, 2, 0),
+		resources.CreateResources(1, 1, 0),
+		1,
+		[]string{"gone", "n1"},
+		[]string{"n1"},
+		nodesSchedulingMetadata)
+	expectedRejectedDriverNodes := []RejectedDriverNode{
+		{NodeName: "gone", NoSchedulingMetadata: true},
+		{NodeName: "n1", Zone: "z1", LimitingResources: []corev1.ResourceName{v1beta2.ResourceCPU, v1beta2.ResourceMemory}},
+	}
+	if !reflect.DeepEqual(expectedRejectedDriverNodes, explanation.RejectedDriverNodes) {
+		t.Fatalf("mismatch in rejected driver nodes, expected: %v, got: %v", expectedRejectedDriverNodes, explanation.RejectedDriverNodes)
+	}
+}
+
+func TestExplanationIsOptIn(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(1, 1, 0, "z1"),
+	})
+	nodePriorityOrder := []string{"n1"}
+
+	for _, binpacker := range []SparkBinPackFunction{TightlyPack, SingleAZTightlyPack, BestDriver(TightlyPackExecutors, 0, nil)} {
+		p := binpacker(
+			context.Background(),
+			resources.CreateResources(2, 2, 0),
+			resources.CreateResources(1, 1, 0),
+			1,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata)
+		if p.HasCapacity {
+			t.Fatalf("expected the application not to fit")
+		}
+		if p.Explanation != nil {
+			t.Fatalf("expected no explanation, got: %v", p.Explanation)
+		}
+	}
+}
+
+func TestExplanationExecutorShortfall(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1_z1": resources.CreateSchedulingMetadata(4, 4, 0, "z1"),
+		"n1_z2": resources.CreateSchedulingMetadata(4, 4, 0, "z2"),
+	})
+	nodePriorityOrder := []string{"n1_z1", "n1_z2"}
+
+	p := WithExplanation(SingleAZTightlyPack)(
+		context.Background(),
+		resources.CreateResources(2, 2, 0),
+		resources.CreateResources(2, 2, 0),
+		3,
+		nodePriorityOrder,
+		nodePriorityOrder,
+		nodesSchedulingMetadata)
+	if p.HasCapacity {
+		t.Fatalf("expected the application not to fit")
+	}
+	if p.Explanation == nil {
+		t.Fatalf("expected an explanation")
+	}
+
+	explanation := p.Explanation
+	if !explanation.DriverFits {
+		t.Fatalf("expected the driver to fit")
+	}
+	expectedRejectedDriverNodes := []RejectedDriverNode{
+		{NodeName: "n1_z1", Zone: "z1", LimitingResources: []corev1.ResourceName{v1beta2.ResourceCPU, v1beta2.ResourceMemory}, ExecutorCapacity: 3},
+		{NodeName: "n1_z2", Zone: "z2", LimitingResources: []corev1.ResourceName{v1beta2.ResourceCPU, v1beta2.ResourceMemory}, ExecutorCapacity: 3},
+	}
+	if !reflect.DeepEqual(expectedRejectedDriverNodes, explanation.RejectedDriverNodes) {
+		t.Fatalf("mismatch in rejected driver nodes, expected: %v, got: %v", expectedRejectedDriverNodes, explanation.RejectedDriverNodes)
+	}
+	if explanation.ExecutorShortfall != 0 {
+		t.Fatalf("mismatch in executor shortfall, expected: %v, got: %v", 0, explanation.ExecutorShortfall)
+	}
+}
+
+func TestExplanationCapsZonesAndCountsNodesOnce(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1_z1": resources.CreateSchedulingMetadata(4, 4, 0, "z1"),
+		"n1_z2": resources.CreateSchedulingMetadata(16, 16, 0, "z2"),
+	})
+
+	explanation := ExplainPackingFailure(
+		resources.CreateResources(32, 32, 0),
+		resources.CreateResources(1, 1, 0),
+		10,
+		[]string{"n1_z1", "n1_z2"},
+		[]string{"n1_z1", "n1_z1", "n1_z2"},
+		nodesSchedulingMetadata)
+	expected := map[string]int{"z1": 4, "z2": 10}
+	if !reflect.DeepEqual(explanation.ExecutorCapacityByZone, expected) {
+		t.Fatalf("mismatch in zone capacities, expected: %v, got: %v", expected, explanation.ExecutorCapacityByZone)
+	}
+}
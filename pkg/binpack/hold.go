@@ -0,0 +1,93 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+// Hold earmarks the capacity of a set of nodes for an application that does not fit yet, typically the head of
+// the queue, so that smaller applications backfilling around it can not keep taking the capacity it waits for
+type Hold struct {
+	// Nodes are the held nodes
+	Nodes map[string]bool
+	// PackingResult is the placement the held application is expected to get once the capacity is freed
+	PackingResult *PackingResult
+}
+
+// ComputeHold finds the nodes to hold for an application that does not fit. The reservations in releasable, e.g.
+// those of running applications, are assumed to be released, and binpacker places the application on the nodes
+// with their capacity returned. The nodes of that placement are held. It returns false when the application does
+// not fit even with all of releasable released, in which case nothing should be held. It returns an error when ctx
+// is done before the placement is found, as whether the application fits is unknown then.
+func ComputeHold(
+	ctx context.Context,
+	binpacker SparkBinPackFunction,
+	driverResources, executorResources *resources.Resources,
+	executorCount int,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	releasable []*v1beta2.ResourceReservation) (*Hold, bool, error) {
+
+	// only the extended resources the application requests matter for whether it fits
+	extendedResourceNames := resources.DimensionsOf(driverResources, executorResources)[3:]
+	released := nodesSchedulingMetadata.WithUsageReleased(resources.UsageForNodes(releasable, extendedResourceNames...))
+	packingResult := binpacker(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, released)
+	if packingResult.TimedOut {
+		return nil, false, werror.Error("timed out computing the hold of the application",
+			werror.SafeParam("executorCount", executorCount))
+	}
+	if !packingResult.HasCapacity {
+		return nil, false, nil
+	}
+	nodes := make(map[string]bool)
+	for _, nodeName := range usedNodes(packingResult) {
+		nodes[nodeName] = true
+	}
+	for _, nodeName := range packingResult.ExtraExecutorNodes {
+		nodes[nodeName] = true
+	}
+	return &Hold{Nodes: nodes, PackingResult: packingResult}, true, nil
+}
+
+// Allows returns true if packingResult does not use any held node, i.e. if it is a valid backfill
+func (h *Hold) Allows(packingResult *PackingResult) bool {
+	for _, nodeName := range usedNodes(packingResult) {
+		if h.Nodes[nodeName] {
+			return false
+		}
+	}
+	for _, nodeName := range packingResult.ExtraExecutorNodes {
+		if h.Nodes[nodeName] {
+			return false
+		}
+	}
+	return true
+}
+
+// NodeFilter returns a NodeFilter that excludes held nodes. Packing backfill candidates with
+// WithNodeFilters(binpacker, hold.NodeFilter()) only returns placements the hold allows.
+func (h *Hold) NodeFilter() NodeFilter {
+	return NodeFilter(func(nodeName string, _ *resources.NodeSchedulingMetadata) (bool, string) {
+		if h.Nodes[nodeName] {
+			return false, "node is held for a queued application"
+		}
+		return true, ""
+	})
+}
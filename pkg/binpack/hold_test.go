@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestHold(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(2, 2, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(2, 2, 0, "zone1"),
+		"n3": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+	}
+	nodePriorityOrder := []string{"n1", "n2", "n3"}
+	runningApplication := &v1beta2.ResourceReservation{
+		Spec: v1beta2.ResourceReservationSpec{Reservations: map[string]v1beta2.Reservation{
+			"driver": {Node: "n1", Resources: v1beta2.ResourceList{
+				string(v1beta2.ResourceCPU):    resource.NewQuantity(6, resource.DecimalSI),
+				string(v1beta2.ResourceMemory): resource.NewQuantity(6, resource.BinarySI),
+			}},
+		}},
+	}
+	pack := func(binpacker SparkBinPackFunction, executorCount int) *PackingResult {
+		return binpacker(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			executorCount,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata)
+	}
+	computeHold := func(ctx context.Context, executorCount int) (*Hold, bool, error) {
+		return ComputeHold(
+			ctx,
+			TightlyPack,
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			executorCount,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata,
+			[]*v1beta2.ResourceReservation{runningApplication})
+	}
+
+	if pack(TightlyPack, 8).HasCapacity {
+		t.Fatalf("expected the application not to fit before releasing")
+	}
+	hold, ok, err := computeHold(context.Background(), 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected a hold")
+	}
+	expectedNodes := map[string]bool{"n1": true, "n2": true}
+	if !reflect.DeepEqual(expectedNodes, hold.Nodes) {
+		t.Fatalf("mismatch in held nodes, expected: %v, got: %v", expectedNodes, hold.Nodes)
+	}
+	if !hold.PackingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+
+	backfill := pack(TightlyPack, 2)
+	if !backfill.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if hold.Allows(backfill) {
+		t.Fatalf("expected backfill on held nodes not to be allowed: %v", backfill)
+	}
+
+	backfill = pack(WithNodeFilters(TightlyPack, hold.NodeFilter()), 2)
+	if !backfill.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if backfill.DriverNode != "n3" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "n3", backfill.DriverNode)
+	}
+	if !hold.Allows(backfill) {
+		t.Fatalf("expected backfill off held nodes to be allowed: %v", backfill)
+	}
+
+	_, ok, err = computeHold(context.Background(), 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected no hold")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok, err = computeHold(ctx, 8)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if ok {
+		t.Fatalf("expected no hold")
+	}
+}
@@ -23,7 +23,8 @@ import (
 )
 
 // MinimalFragmentation is a SparkBinPackFunction that tries to minimize spark app fragmentation across the cluster.
-// see minimalFragmentation for more details.
+// see minimalFragmentation for more details. Like the other strategies, its PackingEfficiencies account for the
+// executors as well as the driver, so SingleAZMinimalFragmentation prefers the zone the executors pack best in.
 var MinimalFragmentation = SparkBinPackFunction(func(
 	ctx context.Context,
 	driverResources, executorResources *resources.Resources,
@@ -33,6 +34,20 @@ var MinimalFragmentation = SparkBinPackFunction(func(
 	return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, minimalFragmentation)
 })
 
+// MinimalFragmentationMultiProfile is a MultiProfileSparkBinPackFunction that places the driver like MinimalFragmentation and then
+// minimizes the fragmentation of each executor group in turn
+var MinimalFragmentationMultiProfile = MultiProfileSparkBinPackFunction(func(
+	ctx context.Context,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+	return SparkBinPackMultiProfile(ctx, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, minimalFragmentation)
+})
+
+// MinimalFragmentationExecutors is the GenericBinPackFunction MinimalFragmentation uses to place executors
+var MinimalFragmentationExecutors = GenericBinPackFunction(minimalFragmentation)
+
 // minimalFragmentation attempts to pack executors onto as few nodes as possible, ideally a single one.
 // nodePriorityOrder is still used as a guideline, i.e. if an application can fit on multiple nodes, it will pick
 // the first eligible node according to nodePriorityOrder. additionally, minimalFragmentation will attempt to avoid
@@ -56,18 +71,24 @@ var MinimalFragmentation = SparkBinPackFunction(func(
 //
 // if instead we have executorCount = 19, then we will return:
 // [f, f, ..., f, a, b], true
+//
+// like every GenericBinPackFunction, the placed executors are tentatively reserved in reservedResources, so that
+// further executor groups and PackingEfficiencies see them
 func minimalFragmentation(
-	_ context.Context,
+	ctx context.Context,
 	executorResources *resources.Resources,
 	executorCount int,
 	nodePriorityOrder []string,
 	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
-	reservedResources resources.NodeGroupResources) ([]string, bool) {
+	reservedResources *resources.Ledger) ([]string, bool) {
 	if executorCount == 0 {
 		return []string{}, true
 	}
+	if isDone(ctx) {
+		return nil, false
+	}
 
-	nodeCapacities := capacity.GetNodeCapacities(nodePriorityOrder, nodeGroupSchedulingMetadata, reservedResources, executorResources)
+	nodeCapacities := capacity.GetNodeCapacitiesFromLedger(nodePriorityOrder, nodeGroupSchedulingMetadata, reservedResources, executorResources)
 	nodeCapacities = capacity.FilterOutNodesWithoutCapacity(nodeCapacities)
 	if len(nodeCapacities) == 0 {
 		return nil, false
@@ -84,16 +105,22 @@ func minimalFragmentation(
 		})
 
 		// try scheduling on a subset of nodes that excludes the 'emptiest' nodes
-		if executorNodes, ok := internalMinimalFragmentation(executorCount, nodeCapacities[:firstNodeWithAtLeastTargetCapacity]); ok {
+		if executorNodes, ok := internalMinimalFragmentation(ctx, executorCount, nodeCapacities[:firstNodeWithAtLeastTargetCapacity]); ok {
+			reserveExecutors(executorNodes, executorResources, reservedResources)
 			return executorNodes, ok
 		}
 	}
 
 	// fall back to using empty nodes
-	return internalMinimalFragmentation(executorCount, nodeCapacities)
+	executorNodes, ok := internalMinimalFragmentation(ctx, executorCount, nodeCapacities)
+	if ok {
+		reserveExecutors(executorNodes, executorResources, reservedResources)
+	}
+	return executorNodes, ok
 }
 
 func internalMinimalFragmentation(
+	ctx context.Context,
 	executorCount int,
 	nodeCapacities []capacity.NodeAndExecutorCapacity) ([]string, bool) {
 	nodeCapacitiesCopy := make([]capacity.NodeAndExecutorCapacity, 0, len(nodeCapacities))
@@ -102,6 +129,9 @@ func internalMinimalFragmentation(
 
 	// as long as we have nodes where we could schedule executors
 	for len(nodeCapacitiesCopy) > 0 {
+		if isDone(ctx) {
+			return nil, false
+		}
 		// pick the first node that could fit all the executors (if there's one)
 		position := sort.Search(len(nodeCapacitiesCopy), func(i int) bool {
 			return nodeCapacitiesCopy[i].Capacity >= executorCount
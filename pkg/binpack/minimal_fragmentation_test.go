@@ -272,3 +272,44 @@ func TestMinimalFragmentation(t *testing.T) {
 		})
 	}
 }
+
+func TestMinimalFragmentationReservesExecutors(t *testing.T) {
+	driverResources := resources.CreateResources(1, 1, 0)
+	executorResources := resources.CreateResources(3, 3, 0)
+
+	t.Run("packing efficiencies account for executors", func(t *testing.T) {
+		nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+			"n1": resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone1"),
+		}
+		packingResult := MinimalFragmentation(
+			context.Background(), driverResources, executorResources, 2, []string{"n1"}, []string{"n1"}, nodesSchedulingMetadata)
+		if !packingResult.HasCapacity {
+			t.Fatalf("expected application to fit")
+		}
+		efficiency := packingResult.PackingEfficiencies["n1"]
+		if efficiency.CPU != 0.7 || efficiency.Memory != 0.7 {
+			t.Fatalf("mismatch in packing efficiency, expected: 0.7, got: %v", efficiency)
+		}
+	})
+
+	t.Run("single AZ prefers the zone executors pack best in", func(t *testing.T) {
+		// the driver packs best in zone1, but the executors pack better next to it in zone2
+		nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+			"n1_z1": resources.CreateSchedulingMetadataWithTotals(2, 10, 2, 10, 0, 0, "zone1"),
+			"n2_z1": resources.CreateSchedulingMetadataWithTotals(100, 100, 100, 100, 0, 0, "zone1"),
+			"n1_z2": resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone2"),
+		}
+		nodePriorityOrder := []string{"n1_z1", "n2_z1", "n1_z2"}
+		packingResult := SingleAZMinimalFragmentation(
+			context.Background(), driverResources, executorResources, 2, nodePriorityOrder, nodePriorityOrder, nodesSchedulingMetadata)
+		if !packingResult.HasCapacity {
+			t.Fatalf("expected application to fit")
+		}
+		if packingResult.DriverNode != "n1_z2" {
+			t.Fatalf("mismatch in driver node, expected: n1_z2, got: %v", packingResult.DriverNode)
+		}
+		if !reflect.DeepEqual(packingResult.ExecutorNodes, []string{"n1_z2", "n1_z2"}) {
+			t.Fatalf("mismatch in executor nodes, expected: [n1_z2 n1_z2], got: %v", packingResult.ExecutorNodes)
+		}
+	})
+}
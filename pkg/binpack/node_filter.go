@@ -0,0 +1,141 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NodeFilter decides whether a node can be used for scheduling. When the node can not be used it returns false,
+// along with a human readable reason.
+type NodeFilter func(nodeName string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) (bool, string)
+
+// FilteredNode is a node that was excluded from binpacking by a NodeFilter
+type FilteredNode struct {
+	NodeName string
+	Reason   string
+}
+
+// ReadyNodes is a NodeFilter that excludes nodes that are not ready
+var ReadyNodes = NodeFilter(func(_ string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) (bool, string) {
+	if !nodeSchedulingMetadata.Ready {
+		return false, "node is not ready"
+	}
+	return true, ""
+})
+
+// SchedulableNodes is a NodeFilter that excludes cordoned nodes
+var SchedulableNodes = NodeFilter(func(_ string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) (bool, string) {
+	if nodeSchedulingMetadata.Unschedulable {
+		return false, "node is unschedulable"
+	}
+	return true, ""
+})
+
+// ToleratedNodes returns a NodeFilter that excludes nodes with a NoSchedule or NoExecute taint that is not
+// tolerated by any of the given tolerations
+func ToleratedNodes(tolerations []corev1.Toleration) NodeFilter {
+	return NodeFilter(func(_ string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) (bool, string) {
+		for i := range nodeSchedulingMetadata.Taints {
+			taint := &nodeSchedulingMetadata.Taints[i]
+			if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+				continue
+			}
+			if !isTolerated(taint, tolerations) {
+				return false, fmt.Sprintf("node has untolerated taint %s", taint.ToString())
+			}
+		}
+		return true, ""
+	})
+}
+
+func isTolerated(taint *corev1.Taint, tolerations []corev1.Toleration) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterNodes returns the nodes in nodePriorityOrder accepted by all filters, in the same order, along with the
+// nodes that were filtered out. Nodes without scheduling metadata are left for the binpack functions to skip.
+func FilterNodes(
+	nodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	filters ...NodeFilter) ([]string, []FilteredNode) {
+	acceptedNodes := make([]string, 0, len(nodePriorityOrder))
+	filteredNodes := make([]FilteredNode, 0)
+	for _, nodeName := range nodePriorityOrder {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if !ok {
+			acceptedNodes = append(acceptedNodes, nodeName)
+			continue
+		}
+		if reason, ok := applyFilters(nodeName, nodeSchedulingMetadata, filters); !ok {
+			filteredNodes = append(filteredNodes, FilteredNode{NodeName: nodeName, Reason: reason})
+			continue
+		}
+		acceptedNodes = append(acceptedNodes, nodeName)
+	}
+	return acceptedNodes, filteredNodes
+}
+
+func applyFilters(nodeName string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata, filters []NodeFilter) (string, bool) {
+	for _, filter := range filters {
+		if ok, reason := filter(nodeName, nodeSchedulingMetadata); !ok {
+			return reason, false
+		}
+	}
+	return "", true
+}
+
+// WithNodeFilters returns a SparkBinPackFunction that only considers driver and executor nodes accepted by all
+// filters before calling binpacker. Nodes that were filtered out are reported in PackingResult.FilteredNodes.
+func WithNodeFilters(binpacker SparkBinPackFunction, filters ...NodeFilter) SparkBinPackFunction {
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		driverNodes, filteredDriverNodes := FilterNodes(driverNodePriorityOrder, nodesSchedulingMetadata, filters...)
+		executorNodes, filteredExecutorNodes := FilterNodes(executorNodePriorityOrder, nodesSchedulingMetadata, filters...)
+
+		packingResult := binpacker(ctx, driverResources, executorResources, executorCount, driverNodes, executorNodes, nodesSchedulingMetadata)
+		packingResult.FilteredNodes = mergeFilteredNodes(filteredDriverNodes, filteredExecutorNodes)
+		return packingResult
+	})
+}
+
+func mergeFilteredNodes(first, second []FilteredNode) []FilteredNode {
+	seen := make(map[string]bool, len(first))
+	merged := make([]FilteredNode, 0, len(first)+len(second))
+	for _, filteredNodes := range [][]FilteredNode{first, second} {
+		for _, filteredNode := range filteredNodes {
+			if seen[filteredNode.NodeName] {
+				continue
+			}
+			seen[filteredNode.NodeName] = true
+			merged = append(merged, filteredNode)
+		}
+	}
+	return merged
+}
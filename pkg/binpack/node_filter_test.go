@@ -0,0 +1,93 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWithNodeFilters(t *testing.T) {
+	gpuTaint := corev1.Taint{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	preferNoScheduleTaint := corev1.Taint{Key: "spot", Value: "true", Effect: corev1.TaintEffectPreferNoSchedule}
+
+	newNode := func(ready, unschedulable bool, taints ...corev1.Taint) *resources.NodeSchedulingMetadata {
+		n := resources.CreateSchedulingMetadata(4, 4, 0, "zone1")
+		n.Ready = ready
+		n.Unschedulable = unschedulable
+		n.Taints = taints
+		return n
+	}
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"not-ready": newNode(false, false),
+		"cordoned":  newNode(true, true),
+		"gpu":       newNode(true, false, gpuTaint),
+		"spot":      newNode(true, false, preferNoScheduleTaint),
+		"ok":        newNode(true, false),
+	})
+	nodePriorityOrder := []string{"not-ready", "cordoned", "gpu", "spot", "ok"}
+
+	tests := []struct {
+		name                  string
+		tolerations           []corev1.Toleration
+		expectedDriverNode    string
+		expectedFilteredNodes []string
+	}{{
+		name:                  "filters out not ready, cordoned and tainted nodes",
+		expectedDriverNode:    "spot",
+		expectedFilteredNodes: []string{"not-ready", "cordoned", "gpu"},
+	}, {
+		name:                  "keeps nodes with tolerated taints",
+		tolerations:           []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists}},
+		expectedDriverNode:    "gpu",
+		expectedFilteredNodes: []string{"not-ready", "cordoned"},
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			binpacker := WithNodeFilters(TightlyPack, ReadyNodes, SchedulableNodes, ToleratedNodes(test.tolerations))
+			p := binpacker(
+				context.Background(),
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				2,
+				nodePriorityOrder,
+				nodePriorityOrder,
+				nodesSchedulingMetadata)
+			if !p.HasCapacity {
+				t.Fatalf("expected the application to fit")
+			}
+			if p.DriverNode != test.expectedDriverNode {
+				t.Fatalf("mismatch in driver node, expected: %v, got: %v", test.expectedDriverNode, p.DriverNode)
+			}
+
+			filteredNodes := make([]string, 0)
+			for _, filteredNode := range p.FilteredNodes {
+				if filteredNode.Reason == "" {
+					t.Fatalf("expected a reason for filtering %v", filteredNode.NodeName)
+				}
+				filteredNodes = append(filteredNodes, filteredNode.NodeName)
+			}
+			if !reflect.DeepEqual(test.expectedFilteredNodes, filteredNodes) {
+				t.Fatalf("mismatch in filtered nodes, expected: %v, got: %v", test.expectedFilteredNodes, filteredNodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ordering builds the node priority orders that binpack functions take, from composable comparators
+// over node scheduling metadata.
+package ordering
+
+import (
+	"math"
+	"sort"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Node is a node considered for a priority order
+type Node struct {
+	Name string
+	*resources.NodeSchedulingMetadata
+}
+
+// Comparator compares two nodes. It returns a negative number when a should come before b, a positive number when
+// b should come before a, and zero when neither is preferred.
+type Comparator func(a, b Node) int
+
+// PriorityOrder returns the names of all nodes in nodesSchedulingMetadata, sorted by comparators. Comparators are
+// applied in order, each breaking the ties of the ones before, and remaining ties are broken by node name, so the
+// order is stable.
+func PriorityOrder(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata, comparators ...Comparator) []string {
+	nodeNames := make([]string, 0, len(nodesSchedulingMetadata))
+	for nodeName := range nodesSchedulingMetadata {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	return Sort(nodeNames, nodesSchedulingMetadata, comparators...)
+}
+
+// Sort returns a copy of nodeNames sorted like PriorityOrder. Nodes without scheduling metadata go last.
+func Sort(nodeNames []string, nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata, comparators ...Comparator) []string {
+	nodes := make([]Node, 0, len(nodeNames))
+	missing := make([]string, 0)
+	for _, nodeName := range nodeNames {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if !ok {
+			missing = append(missing, nodeName)
+			continue
+		}
+		nodes = append(nodes, Node{Name: nodeName, NodeSchedulingMetadata: nodeSchedulingMetadata})
+	}
+	compare := Chain(append(append(make([]Comparator, 0, len(comparators)+1), comparators...), ByName)...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return compare(nodes[i], nodes[j]) < 0
+	})
+	sort.Strings(missing)
+
+	sorted := make([]string, 0, len(nodeNames))
+	for _, node := range nodes {
+		sorted = append(sorted, node.Name)
+	}
+	return append(sorted, missing...)
+}
+
+// Chain returns a Comparator that applies comparators in order until one of them prefers a node
+func Chain(comparators ...Comparator) Comparator {
+	return func(a, b Node) int {
+		for _, comparator := range comparators {
+			if c := comparator(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+}
+
+// Reverse returns a Comparator that prefers the nodes comparator does not
+func Reverse(comparator Comparator) Comparator {
+	return func(a, b Node) int {
+		return comparator(b, a)
+	}
+}
+
+// ByName prefers nodes with alphabetically smaller names
+func ByName(a, b Node) int {
+	return compareStrings(a.Name, b.Name)
+}
+
+// OldestFirst prefers nodes created earlier
+func OldestFirst(a, b Node) int {
+	switch {
+	case a.CreationTimestamp.Before(b.CreationTimestamp):
+		return -1
+	case b.CreationTimestamp.Before(a.CreationTimestamp):
+		return 1
+	}
+	return 0
+}
+
+// NewestFirst prefers nodes created later
+var NewestFirst = Reverse(OldestFirst)
+
+// LeastAvailable returns a Comparator that prefers nodes with less of resourceName available, which packs
+// applications onto nodes that are already in use
+func LeastAvailable(resourceName corev1.ResourceName) Comparator {
+	return func(a, b Node) int {
+		available := a.AvailableResources.Get(resourceName)
+		return available.Cmp(b.AvailableResources.Get(resourceName))
+	}
+}
+
+// MostAvailable returns a Comparator that prefers nodes with more of resourceName available, which spreads
+// applications across nodes
+func MostAvailable(resourceName corev1.ResourceName) Comparator {
+	return Reverse(LeastAvailable(resourceName))
+}
+
+// DominantUtilization returns the highest fraction of the schedulable CPU, memory or GPU of a node that is not
+// available
+func DominantUtilization(nodeSchedulingMetadata *resources.NodeSchedulingMetadata) float64 {
+	schedulable := nodeSchedulingMetadata.SchedulableResources
+	available := nodeSchedulingMetadata.AvailableResources
+	utilization := 0.0
+	for _, r := range [][2]float64{
+		{schedulable.CPU.AsApproximateFloat64(), available.CPU.AsApproximateFloat64()},
+		{schedulable.Memory.AsApproximateFloat64(), available.Memory.AsApproximateFloat64()},
+		{schedulable.NvidiaGPU.AsApproximateFloat64(), available.NvidiaGPU.AsApproximateFloat64()},
+	} {
+		if r[0] > 0 {
+			utilization = math.Max(utilization, 1-r[1]/r[0])
+		}
+	}
+	return utilization
+}
+
+// MostUtilized prefers nodes with a higher DominantUtilization, which keeps lightly used nodes free to be scaled
+// down
+func MostUtilized(a, b Node) int {
+	return compareFloats(DominantUtilization(b.NodeSchedulingMetadata), DominantUtilization(a.NodeSchedulingMetadata))
+}
+
+// ZonePreference returns a Comparator that prefers nodes in zones that come earlier in zones. Nodes in zones that
+// are not listed come last.
+func ZonePreference(zones ...string) Comparator {
+	rank := make(map[string]int, len(zones))
+	for i, zone := range zones {
+		if _, ok := rank[zone]; !ok {
+			rank[zone] = i
+		}
+	}
+	zoneRank := func(node Node) int {
+		if r, ok := rank[node.ZoneLabel]; ok {
+			return r
+		}
+		return len(zones)
+	}
+	return func(a, b Node) int {
+		return zoneRank(a) - zoneRank(b)
+	}
+}
+
+// LabelMatch returns a Comparator that prefers nodes with the label key set to value
+func LabelMatch(key, value string) Comparator {
+	matches := func(node Node) bool {
+		v, ok := node.AllLabels[key]
+		return ok && v == value
+	}
+	return func(a, b Node) int {
+		return compareBools(matches(a), matches(b))
+	}
+}
+
+// FewestReservations returns a Comparator that prefers nodes with fewer reservations in resourceReservations,
+// counting the driver and each executor reservation separately
+func FewestReservations(resourceReservations []*v1beta2.ResourceReservation) Comparator {
+	reservationCounts := make(map[string]int)
+	for _, resourceReservation := range resourceReservations {
+		for _, reservation := range resourceReservation.Spec.Reservations {
+			reservationCounts[reservation.Node]++
+		}
+	}
+	return func(a, b Node) int {
+		return reservationCounts[a.Name] - reservationCounts[b.Name]
+	}
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareBools prefers true over false
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	}
+	return 1
+}
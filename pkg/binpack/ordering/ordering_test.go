@@ -0,0 +1,112 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ordering
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func testNodes() resources.NodeGroupSchedulingMetadata {
+	created := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	node := func(availableCPU int64, zone string, age time.Duration, labels map[string]string) *resources.NodeSchedulingMetadata {
+		nodeSchedulingMetadata := resources.CreateSchedulingMetadataWithTotals(availableCPU, 8, 8, 8, 0, 0, zone)
+		nodeSchedulingMetadata.CreationTimestamp = created.Add(-age)
+		nodeSchedulingMetadata.AllLabels = labels
+		return nodeSchedulingMetadata
+	}
+	return resources.NodeGroupSchedulingMetadata{
+		"a": node(4, "zone1", time.Hour, nil),
+		"b": node(2, "zone2", 3*time.Hour, map[string]string{"pool": "spark"}),
+		"c": node(6, "zone2", 2*time.Hour, map[string]string{"pool": "other"}),
+		"d": node(4, "zone3", time.Hour, map[string]string{"pool": "spark"}),
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	resourceReservations := []*v1beta2.ResourceReservation{{
+		Spec: v1beta2.ResourceReservationSpec{Reservations: map[string]v1beta2.Reservation{
+			"driver":     {Node: "a"},
+			"executor-1": {Node: "a"},
+			"executor-2": {Node: "d"},
+		}},
+	}}
+
+	tests := []struct {
+		name        string
+		comparators []Comparator
+		expected    []string
+	}{{
+		name:     "ties are broken by name",
+		expected: []string{"a", "b", "c", "d"},
+	}, {
+		name:        "oldest first",
+		comparators: []Comparator{OldestFirst},
+		expected:    []string{"b", "c", "a", "d"},
+	}, {
+		name:        "newest first",
+		comparators: []Comparator{NewestFirst},
+		expected:    []string{"a", "d", "c", "b"},
+	}, {
+		name:        "least available",
+		comparators: []Comparator{LeastAvailable(corev1.ResourceCPU)},
+		expected:    []string{"b", "a", "d", "c"},
+	}, {
+		name:        "most available",
+		comparators: []Comparator{MostAvailable(corev1.ResourceCPU)},
+		expected:    []string{"c", "a", "d", "b"},
+	}, {
+		name:        "most utilized",
+		comparators: []Comparator{MostUtilized},
+		expected:    []string{"b", "a", "d", "c"},
+	}, {
+		name:        "zone preference puts unlisted zones last",
+		comparators: []Comparator{ZonePreference("zone3", "zone1")},
+		expected:    []string{"d", "a", "b", "c"},
+	}, {
+		name:        "label match",
+		comparators: []Comparator{LabelMatch("pool", "spark")},
+		expected:    []string{"b", "d", "a", "c"},
+	}, {
+		name:        "fewest reservations",
+		comparators: []Comparator{FewestReservations(resourceReservations)},
+		expected:    []string{"b", "c", "d", "a"},
+	}, {
+		name:        "comparators break ties of earlier ones",
+		comparators: []Comparator{ZonePreference("zone2"), MostAvailable(corev1.ResourceCPU)},
+		expected:    []string{"c", "b", "a", "d"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if order := PriorityOrder(testNodes(), test.comparators...); !reflect.DeepEqual(test.expected, order) {
+				t.Fatalf("mismatch in priority order, expected: %v, got: %v", test.expected, order)
+			}
+		})
+	}
+}
+
+func TestSortPutsNodesWithoutMetadataLast(t *testing.T) {
+	sorted := Sort([]string{"missing", "c", "a"}, testNodes(), OldestFirst)
+	expected := []string{"c", "a", "missing"}
+	if !reflect.DeepEqual(expected, sorted) {
+		t.Fatalf("mismatch in sorted nodes, expected: %v, got: %v", expected, sorted)
+	}
+}
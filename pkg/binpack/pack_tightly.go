@@ -31,32 +31,52 @@ var TightlyPack = SparkBinPackFunction(func(
 	return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, tightlyPackExecutors)
 })
 
+// TightlyPackMultiProfile is a MultiProfileSparkBinPackFunction that places the driver like TightlyPack and then
+// tightly packs each executor group in turn
+var TightlyPackMultiProfile = MultiProfileSparkBinPackFunction(func(
+	ctx context.Context,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+	return SparkBinPackMultiProfile(ctx, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, tightlyPackExecutors)
+})
+
+// TightlyPackExecutors is the GenericBinPackFunction TightlyPack uses to place executors
+var TightlyPackExecutors = GenericBinPackFunction(tightlyPackExecutors)
+
 func tightlyPackExecutors(
 	ctx context.Context,
 	executorResources *resources.Resources,
 	executorCount int,
 	nodePriorityOrder []string,
 	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
-	reservedResources resources.NodeGroupResources) ([]string, bool) {
+	reservedResources *resources.Ledger) ([]string, bool) {
 	executorNodes := make([]string, 0, executorCount)
 	if executorCount == 0 {
 		return executorNodes, true
 	}
+	// executors are reserved on a snapshot as they are placed, so that nodes listed twice are not given more
+	// executors than they fit, and nothing needs to be released when they do not all fit
+	attempt := reservedResources.Snapshot()
+	nodeCapacity := executorCapacity(nodesSchedulingMetadata, attempt, executorResources)
+	dimensions := resources.DimensionsOf(executorResources)
+	executor := dimensions.Vector(executorResources)
 	for _, n := range nodePriorityOrder {
-		if reservedResources[n] == nil {
-			reservedResources[n] = resources.Zero()
+		if isDone(ctx) {
+			return nil, false
 		}
-		for {
-			reservedResources[n].Add(executorResources)
-			nodeSchedulingMetadata, ok := nodesSchedulingMetadata[n]
-			if !ok || reservedResources[n].GreaterThan(nodeSchedulingMetadata.AvailableResources) {
-				reservedResources[n].Sub(executorResources)
-				break
-			}
+		count := minInt(nodeCapacity(n), executorCount-len(executorNodes))
+		if count <= 0 {
+			continue
+		}
+		attempt.ReserveVectorTimes(dimensions, n, executor, count)
+		for i := 0; i < count; i++ {
 			executorNodes = append(executorNodes, n)
-			if len(executorNodes) == executorCount {
-				return executorNodes, true
-			}
+		}
+		if len(executorNodes) == executorCount {
+			reservedResources.Merge(attempt)
+			return executorNodes, true
 		}
 	}
 	return nil, false
@@ -0,0 +1,173 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"sort"
+	"sync"
+
+	werror "github.com/palantir/witchcraft-go-error"
+)
+
+const (
+	// TightlyPackStrategyName is the registered name of TightlyPack
+	TightlyPackStrategyName = "tightly-pack"
+	// DistributeEvenlyStrategyName is the registered name of DistributeEvenly
+	DistributeEvenlyStrategyName = "distribute-evenly"
+	// MinimalFragmentationStrategyName is the registered name of MinimalFragmentation
+	MinimalFragmentationStrategyName = "minimal-fragmentation"
+	// AzAwareTightlyPackStrategyName is the registered name of AzAwareTightlyPack
+	AzAwareTightlyPackStrategyName = "az-aware-tightly-pack"
+	// SingleAZTightlyPackStrategyName is the registered name of SingleAZTightlyPack
+	SingleAZTightlyPackStrategyName = "single-az-tightly-pack"
+	// SingleAZMinimalFragmentationStrategyName is the registered name of SingleAZMinimalFragmentation
+	SingleAZMinimalFragmentationStrategyName = "single-az-minimal-fragmentation"
+	// ZoneSpreadTightlyPackStrategyName is the registered name of ZoneSpreadTightlyPack
+	ZoneSpreadTightlyPackStrategyName = "zone-spread-tightly-pack"
+)
+
+// Strategy is a SparkBinPackFunction registered under a stable name, along with metadata describing its behavior
+type Strategy struct {
+	Name     string
+	Function SparkBinPackFunction
+	// ZoneAware is true when the strategy takes the zone of each node into account
+	ZoneAware bool
+	// SingleZone is true when the strategy tries to place the whole application in a single zone. Strategies that
+	// spread an application across zones, like ZoneSpreadTightlyPack, are zone aware but not single zone.
+	SingleZone bool
+	// CanFallBack is true when the strategy falls back to a less preferred placement, e.g. across zones,
+	// when its preferred placement does not fit
+	CanFallBack bool
+	// Constrained builds the strategy with its executors placed under constraints, see WithExecutorConstraints.
+	// It is set for all strategies registered by this package.
+	Constrained func(constraints ExecutorConstraints) SparkBinPackFunction
+}
+
+// WithExecutorConstraints returns the function of the strategy with its executors placed under constraints. It
+// fails for strategies registered without Constrained, unless constraints is the zero value.
+func (s Strategy) WithExecutorConstraints(constraints ExecutorConstraints) (SparkBinPackFunction, error) {
+	if s.Constrained != nil {
+		return s.Constrained(constraints), nil
+	}
+	if constraints != (ExecutorConstraints{}) {
+		return nil, werror.Error("binpack strategy does not support executor constraints",
+			werror.SafeParam("strategyName", s.Name))
+	}
+	return s.Function, nil
+}
+
+var defaultRegistry = newStrategyRegistry()
+
+func init() {
+	for _, strategy := range []Strategy{
+		{Name: TightlyPackStrategyName, Function: TightlyPack,
+			Constrained: constrainedWith(sparkBinPackWith, tightlyPackExecutors)},
+		{Name: DistributeEvenlyStrategyName, Function: DistributeEvenly,
+			Constrained: constrainedWith(sparkBinPackWith, distributeExecutorsEvenly)},
+		{Name: MinimalFragmentationStrategyName, Function: MinimalFragmentation,
+			Constrained: constrainedWith(sparkBinPackWith, minimalFragmentation)},
+		{Name: AzAwareTightlyPackStrategyName, Function: AzAwareTightlyPack, ZoneAware: true, SingleZone: true, CanFallBack: true,
+			Constrained: constrainedWith(getAzAwareSparkBinFunction, tightlyPackExecutors)},
+		{Name: SingleAZTightlyPackStrategyName, Function: SingleAZTightlyPack, ZoneAware: true, SingleZone: true,
+			Constrained: constrainedWith(getSingleAZSparkBinFunction, tightlyPackExecutors)},
+		{Name: SingleAZMinimalFragmentationStrategyName, Function: SingleAZMinimalFragmentation, ZoneAware: true, SingleZone: true,
+			Constrained: constrainedWith(getSingleAZSparkBinFunction, minimalFragmentation)},
+		{Name: ZoneSpreadTightlyPackStrategyName, Function: ZoneSpreadTightlyPack, ZoneAware: true,
+			Constrained: constrainedWith(sparkBinPackWith, SpreadAcrossZones(TightlyPackExecutors, 1))},
+	} {
+		if err := RegisterStrategy(strategy); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// constrainedWith returns a Strategy.Constrained that builds a strategy with build, placing executors with
+// distributeExecutors under the given constraints
+func constrainedWith(
+	build func(distributeExecutors GenericBinPackFunction) SparkBinPackFunction,
+	distributeExecutors GenericBinPackFunction) func(constraints ExecutorConstraints) SparkBinPackFunction {
+	return func(constraints ExecutorConstraints) SparkBinPackFunction {
+		return build(WithExecutorConstraints(distributeExecutors, constraints))
+	}
+}
+
+// sparkBinPackWith returns the SparkBinPackFunction that places executors with distributeExecutors, like SparkBinPack
+func sparkBinPackWith(distributeExecutors GenericBinPackFunction) SparkBinPackFunction {
+	return SparkBinPackWithDriverPlacement(DriverPlacementAny, distributeExecutors)
+}
+
+// RegisterStrategy makes a strategy available under its name. Names must be unique.
+func RegisterStrategy(strategy Strategy) error {
+	return defaultRegistry.register(strategy)
+}
+
+// LookupStrategy returns the strategy registered under name
+func LookupStrategy(name string) (Strategy, bool) {
+	return defaultRegistry.lookup(name)
+}
+
+// Strategies returns all registered strategies, ordered by name
+func Strategies() []Strategy {
+	return defaultRegistry.list()
+}
+
+type strategyRegistry struct {
+	lock       sync.RWMutex
+	strategies map[string]Strategy
+}
+
+func newStrategyRegistry() *strategyRegistry {
+	return &strategyRegistry{
+		strategies: make(map[string]Strategy),
+	}
+}
+
+func (r *strategyRegistry) register(strategy Strategy) error {
+	if strategy.Name == "" {
+		return werror.Error("binpack strategy name must not be empty")
+	}
+	if strategy.Function == nil {
+		return werror.Error("binpack strategy function must not be nil",
+			werror.SafeParam("strategyName", strategy.Name))
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.strategies[strategy.Name]; ok {
+		return werror.Error("binpack strategy is already registered",
+			werror.SafeParam("strategyName", strategy.Name))
+	}
+	r.strategies[strategy.Name] = strategy
+	return nil
+}
+
+func (r *strategyRegistry) lookup(name string) (Strategy, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	strategy, ok := r.strategies[name]
+	return strategy, ok
+}
+
+func (r *strategyRegistry) list() []Strategy {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	strategies := make([]Strategy, 0, len(r.strategies))
+	for _, strategy := range r.strategies {
+		strategies = append(strategies, strategy)
+	}
+	sort.Slice(strategies, func(i, j int) bool {
+		return strategies[i].Name < strategies[j].Name
+	})
+	return strategies
+}
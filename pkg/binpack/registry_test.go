@@ -0,0 +1,137 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestBuiltInStrategies(t *testing.T) {
+	names := make([]string, 0)
+	for _, strategy := range Strategies() {
+		names = append(names, strategy.Name)
+	}
+	expected := []string{
+		AzAwareTightlyPackStrategyName,
+		DistributeEvenlyStrategyName,
+		MinimalFragmentationStrategyName,
+		SingleAZMinimalFragmentationStrategyName,
+		SingleAZTightlyPackStrategyName,
+		TightlyPackStrategyName,
+		ZoneSpreadTightlyPackStrategyName,
+	}
+	if !reflect.DeepEqual(expected, names) {
+		t.Fatalf("mismatch in strategy names, expected: %v, got: %v", expected, names)
+	}
+
+	strategy, ok := LookupStrategy(AzAwareTightlyPackStrategyName)
+	if !ok {
+		t.Fatalf("expected %v to be registered", AzAwareTightlyPackStrategyName)
+	}
+	if !strategy.ZoneAware || !strategy.SingleZone || !strategy.CanFallBack {
+		t.Fatalf("expected %v to be zone aware and single zone with fall back, got: %+v", AzAwareTightlyPackStrategyName, strategy)
+	}
+
+	strategy, ok = LookupStrategy(ZoneSpreadTightlyPackStrategyName)
+	if !ok {
+		t.Fatalf("expected %v to be registered", ZoneSpreadTightlyPackStrategyName)
+	}
+	if !strategy.ZoneAware || strategy.SingleZone {
+		t.Fatalf("expected %v to be zone aware but not single zone, got: %+v", ZoneSpreadTightlyPackStrategyName, strategy)
+	}
+
+	_, ok = LookupStrategy("unknown")
+	if ok {
+		t.Fatalf("expected unknown strategies not to be registered")
+	}
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	registry := newStrategyRegistry()
+	if err := registry.register(Strategy{Name: "custom", Function: TightlyPack}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	strategy, ok := registry.lookup("custom")
+	if !ok {
+		t.Fatalf("expected custom to be registered")
+	}
+	if strategy.ZoneAware || strategy.SingleZone {
+		t.Fatalf("expected custom not to be zone aware")
+	}
+
+	for _, invalid := range []Strategy{
+		{Name: "custom", Function: DistributeEvenly},
+		{Name: "", Function: DistributeEvenly},
+		{Name: "no-function"},
+	} {
+		if registry.register(invalid) == nil {
+			t.Fatalf("expected an error registering %q", invalid.Name)
+		}
+	}
+	if len(registry.list()) != 1 {
+		t.Fatalf("expected %v strategy, got: %v", 1, registry.list())
+	}
+}
+
+func TestStrategyWithExecutorConstraints(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n3": resources.CreateSchedulingMetadata(8, 8, 0, "zone2"),
+		"n4": resources.CreateSchedulingMetadata(8, 8, 0, "zone2"),
+	}
+	nodePriorityOrder := []string{"n1", "n2", "n3", "n4"}
+	constraints := ExecutorConstraints{MaxExecutorsPerNode: 2}
+
+	for _, strategy := range Strategies() {
+		binpacker, err := strategy.WithExecutorConstraints(constraints)
+		if err != nil {
+			t.Fatalf("unexpected error constraining %v: %v", strategy.Name, err)
+		}
+		p := binpacker(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			4,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata)
+		if !p.HasCapacity {
+			t.Fatalf("expected the application to fit with %v", strategy.Name)
+		}
+		counts := make(map[string]int)
+		for _, nodeName := range p.ExecutorNodes {
+			counts[nodeName]++
+		}
+		for nodeName, count := range counts {
+			if count > constraints.MaxExecutorsPerNode {
+				t.Fatalf("%v placed %v executors on %v, expected at most %v", strategy.Name, count, nodeName, constraints.MaxExecutorsPerNode)
+			}
+		}
+	}
+
+	custom := Strategy{Name: "custom", Function: TightlyPack}
+	if _, err := custom.WithExecutorConstraints(constraints); err == nil {
+		t.Fatalf("expected an error constraining a strategy without Constrained")
+	}
+	if _, err := custom.WithExecutorConstraints(ExecutorConstraints{}); err != nil {
+		t.Fatalf("unexpected error without constraints: %v", err)
+	}
+}
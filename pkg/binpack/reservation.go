@@ -0,0 +1,165 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta1"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	werror "github.com/palantir/witchcraft-go-error"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DriverReservationName is the key of the driver's reservation in a ResourceReservation
+	DriverReservationName     = "driver"
+	executorReservationPrefix = "executor-"
+)
+
+// ExecutorReservationName returns the key of the reservation of the i-th executor in a ResourceReservation,
+// counting from 1
+func ExecutorReservationName(i int) string {
+	return fmt.Sprintf("%s%d", executorReservationPrefix, i)
+}
+
+// NewResourceReservation creates the ResourceReservation of the application of driver, as placed by packingResult.
+// The reservation is named after the application ID, labelled with the application ID and instanceGroup, owned by
+// driver, and has the driver already bound to it.
+//
+// The resources of each executor are taken from executorGroups, indexed by packingResult.ExecutorProfiles when it
+// is set, and from the first group otherwise. Only the executors in ExecutorNodes are reserved. Executors in
+// ExtraExecutorNodes are not guaranteed and are left to the caller, e.g. as soft reservations. It returns an error
+// if driver has no application ID label or if an executor refers to a group that does not exist.
+func NewResourceReservation(
+	driver *corev1.Pod,
+	instanceGroup string,
+	packingResult *PackingResult,
+	driverResources *resources.Resources,
+	executorGroups []ExecutorGroup) (*v1beta2.ResourceReservation, error) {
+
+	appID := driver.Labels[v1beta1.AppIDLabel]
+	if appID == "" {
+		return nil, werror.Error("driver has no application ID label",
+			werror.SafeParam("label", v1beta1.AppIDLabel))
+	}
+	reservations := make(map[string]v1beta2.Reservation, len(packingResult.ExecutorNodes)+1)
+	reservations[DriverReservationName] = v1beta2.Reservation{
+		Node:      packingResult.DriverNode,
+		Resources: reservationResourceList(driverResources),
+	}
+	for i, executorNode := range packingResult.ExecutorNodes {
+		profile := 0
+		if len(packingResult.ExecutorProfiles) > i {
+			profile = packingResult.ExecutorProfiles[i]
+		}
+		if profile < 0 || profile >= len(executorGroups) {
+			return nil, werror.Error("executor profile does not refer to an executor group",
+				werror.SafeParam("executorProfile", profile),
+				werror.SafeParam("executorGroupCount", len(executorGroups)))
+		}
+		reservations[ExecutorReservationName(i+1)] = v1beta2.Reservation{
+			Node:      executorNode,
+			Resources: reservationResourceList(executorGroups[profile].Resources),
+		}
+	}
+
+	return &v1beta2.ResourceReservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      appID,
+			Namespace: driver.Namespace,
+			Labels: map[string]string{
+				v1beta1.AppIDLabel:         appID,
+				v1beta1.InstanceGroupLabel: instanceGroup,
+			},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(driver, corev1.SchemeGroupVersion.WithKind("Pod"))},
+		},
+		Spec: v1beta2.ResourceReservationSpec{
+			Reservations: reservations,
+		},
+		Status: v1beta2.ResourceReservationStatus{
+			Pods: map[string]string{DriverReservationName: driver.Name},
+		},
+	}, nil
+}
+
+// reservationResourceList converts r to the ResourceList of a reservation, see resources.Resources.ToResourceList
+func reservationResourceList(r *resources.Resources) v1beta2.ResourceList {
+	resourceList := r.ToResourceList()
+	reservationResources := make(v1beta2.ResourceList, len(resourceList))
+	for name, quantity := range resourceList {
+		quantity := quantity
+		reservationResources[string(name)] = &quantity
+	}
+	return reservationResources
+}
+
+// ReservationSlot is a single reservation of a ResourceReservation
+type ReservationSlot struct {
+	// Name is the key of the reservation, e.g. executor-1
+	Name      string
+	Node      string
+	Resources *resources.Resources
+	// PodName is the name of the pod bound to the reservation, or empty if it is not bound
+	PodName string
+}
+
+// ExecutorSlots returns the executor reservations of resourceReservation ordered by executor index, which is the
+// inverse of NewResourceReservation. Reservations that are not named by ExecutorReservationName are skipped. Only
+// the given extended resources are kept in the resources of each slot besides CPU, Memory and NvidiaGPU.
+func ExecutorSlots(resourceReservation *v1beta2.ResourceReservation, extendedResourceNames ...corev1.ResourceName) []ReservationSlot {
+	indices := make(map[string]int, len(resourceReservation.Spec.Reservations))
+	slots := make([]ReservationSlot, 0, len(resourceReservation.Spec.Reservations))
+	for name, reservation := range resourceReservation.Spec.Reservations {
+		if !strings.HasPrefix(name, executorReservationPrefix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(name, executorReservationPrefix))
+		if err != nil {
+			continue
+		}
+		reservation := reservation
+		reservationResources := resources.Zero()
+		reservationResources.AddFromReservation(&reservation, extendedResourceNames...)
+		indices[name] = index
+		slots = append(slots, ReservationSlot{
+			Name:      name,
+			Node:      reservation.Node,
+			Resources: reservationResources,
+			PodName:   resourceReservation.Status.Pods[name],
+		})
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		return indices[slots[i].Name] < indices[slots[j].Name]
+	})
+	return slots
+}
+
+// UnboundExecutorSlots returns the executor reservations of resourceReservation that no pod is bound to, ordered by
+// executor index, see ExecutorSlots
+func UnboundExecutorSlots(resourceReservation *v1beta2.ResourceReservation, extendedResourceNames ...corev1.ResourceName) []ReservationSlot {
+	unbound := make([]ReservationSlot, 0)
+	for _, slot := range ExecutorSlots(resourceReservation, extendedResourceNames...) {
+		if slot.PodName == "" {
+			unbound = append(unbound, slot)
+		}
+	}
+	return unbound
+}
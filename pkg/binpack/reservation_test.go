@@ -0,0 +1,166 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta1"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNewResourceReservation(t *testing.T) {
+	driver := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app-driver",
+			Namespace: "namespace",
+			UID:       types.UID("uid"),
+			Labels:    map[string]string{v1beta1.AppIDLabel: "app"},
+		},
+	}
+	packingResult := &PackingResult{
+		DriverNode:       "n1",
+		ExecutorNodes:    []string{"n1", "n2", "n2"},
+		ExecutorProfiles: []int{0, 1, 1},
+		HasCapacity:      true,
+	}
+	executorGroups := []ExecutorGroup{
+		{Resources: resources.CreateResources(1, 2, 0), Count: 1},
+		{Resources: resources.CreateResources(2, 4, 1), Count: 2},
+	}
+
+	resourceReservation, err := NewResourceReservation(driver, "batch", packingResult, resources.CreateResources(3, 3, 0), executorGroups)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resourceReservation.Name != "app" {
+		t.Fatalf("mismatch in name, expected: %v, got: %v", "app", resourceReservation.Name)
+	}
+	if resourceReservation.Namespace != "namespace" {
+		t.Fatalf("mismatch in namespace, expected: %v, got: %v", "namespace", resourceReservation.Namespace)
+	}
+	expectedLabels := map[string]string{v1beta1.AppIDLabel: "app", v1beta1.InstanceGroupLabel: "batch"}
+	if !reflect.DeepEqual(expectedLabels, resourceReservation.Labels) {
+		t.Fatalf("mismatch in labels, expected: %v, got: %v", expectedLabels, resourceReservation.Labels)
+	}
+	if len(resourceReservation.OwnerReferences) != 1 {
+		t.Fatalf("expected %v owner references, got: %v", 1, resourceReservation.OwnerReferences)
+	}
+	if resourceReservation.OwnerReferences[0].Kind != "Pod" {
+		t.Fatalf("mismatch in owner kind, expected: %v, got: %v", "Pod", resourceReservation.OwnerReferences[0].Kind)
+	}
+	if resourceReservation.OwnerReferences[0].UID != driver.UID {
+		t.Fatalf("mismatch in owner UID, expected: %v, got: %v", driver.UID, resourceReservation.OwnerReferences[0].UID)
+	}
+	expectedPods := map[string]string{DriverReservationName: "app-driver"}
+	if !reflect.DeepEqual(expectedPods, resourceReservation.Status.Pods) {
+		t.Fatalf("mismatch in pods, expected: %v, got: %v", expectedPods, resourceReservation.Status.Pods)
+	}
+
+	if len(resourceReservation.Spec.Reservations) != 4 {
+		t.Fatalf("expected %v reservations, got: %v", 4, resourceReservation.Spec.Reservations)
+	}
+	driverReservation := resourceReservation.Spec.Reservations[DriverReservationName]
+	driverReservationResources := resources.Zero()
+	driverReservationResources.AddFromReservation(&driverReservation)
+	if driverReservation.Node != "n1" {
+		t.Fatalf("mismatch in node, expected: %v, got: %v", "n1", driverReservation.Node)
+	}
+	if !driverReservationResources.Eq(resources.CreateResources(3, 3, 0)) {
+		t.Fatalf("mismatch in driver resources, got: %v", driverReservationResources)
+	}
+	if _, ok := driverReservation.Resources[string(v1beta2.ResourceNvidiaGPU)]; ok {
+		t.Fatalf("expected no %v in the driver reservation, got: %v", v1beta2.ResourceNvidiaGPU, driverReservation.Resources)
+	}
+
+	resourceReservation.Status.Pods[ExecutorReservationName(2)] = "app-exec-2"
+	slots := ExecutorSlots(resourceReservation)
+	if len(slots) != 3 {
+		t.Fatalf("expected %v slots, got: %v", 3, slots)
+	}
+	for i, slot := range slots {
+		if slot.Name != ExecutorReservationName(i+1) {
+			t.Fatalf("mismatch in name, expected: %v, got: %v", ExecutorReservationName(i+1), slot.Name)
+		}
+		if slot.Node != packingResult.ExecutorNodes[i] {
+			t.Fatalf("mismatch in node, expected: %v, got: %v", packingResult.ExecutorNodes[i], slot.Node)
+		}
+		if !slot.Resources.Eq(executorGroups[packingResult.ExecutorProfiles[i]].Resources) {
+			t.Fatalf("mismatch in resources of %s, got: %v", slot.Name, slot.Resources)
+		}
+	}
+	if slots[1].PodName != "app-exec-2" {
+		t.Fatalf("mismatch in pod name, expected: %v, got: %v", "app-exec-2", slots[1].PodName)
+	}
+
+	unbound := UnboundExecutorSlots(resourceReservation)
+	if len(unbound) != 2 {
+		t.Fatalf("expected %v unbound slots, got: %v", 2, unbound)
+	}
+	if unbound[0].Name != ExecutorReservationName(1) {
+		t.Fatalf("mismatch in name, expected: %v, got: %v", ExecutorReservationName(1), unbound[0].Name)
+	}
+	if unbound[1].Name != ExecutorReservationName(3) {
+		t.Fatalf("mismatch in name, expected: %v, got: %v", ExecutorReservationName(3), unbound[1].Name)
+	}
+}
+
+func TestExecutorSlotsOrdersByIndex(t *testing.T) {
+	packingResult := &PackingResult{DriverNode: "n", ExecutorNodes: make([]string, 12)}
+	for i := range packingResult.ExecutorNodes {
+		packingResult.ExecutorNodes[i] = ExecutorReservationName(i + 1)
+	}
+	driver := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "driver", Labels: map[string]string{v1beta1.AppIDLabel: "app"}}}
+	resourceReservation, err := NewResourceReservation(driver, "", packingResult, resources.Zero(), []ExecutorGroup{{Resources: resources.Zero()}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nodes := make([]string, 0)
+	for _, slot := range ExecutorSlots(resourceReservation) {
+		nodes = append(nodes, slot.Node)
+	}
+	if !reflect.DeepEqual(packingResult.ExecutorNodes, nodes) {
+		t.Fatalf("mismatch in slot nodes, expected: %v, got: %v", packingResult.ExecutorNodes, nodes)
+	}
+}
+
+func TestNewResourceReservationErrors(t *testing.T) {
+	driver := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "driver", Labels: map[string]string{v1beta1.AppIDLabel: "app"}}}
+	packingResult := &PackingResult{DriverNode: "n1", ExecutorNodes: []string{"n1", "n2"}, ExecutorProfiles: []int{0, 1}}
+	executorGroups := []ExecutorGroup{{Resources: resources.Zero()}, {Resources: resources.Zero()}}
+
+	_, err := NewResourceReservation(driver, "", packingResult, resources.Zero(), nil)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	_, err = NewResourceReservation(driver, "", packingResult, resources.Zero(), executorGroups[:1])
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	_, err = NewResourceReservation(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "driver"}}, "", packingResult, resources.Zero(), executorGroups)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	_, err = NewResourceReservation(driver, "", packingResult, resources.Zero(), executorGroups)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"sort"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/binpack/ordering"
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+// ScaleDownCandidates picks the nodes the autoscaler would like to remove, which packing should stay away from
+type ScaleDownCandidates func(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) map[string]bool
+
+// ScaleDownImpact describes how a placement gets in the way of scaling down. A node can only be removed once
+// nothing is reserved on it, so every scale down candidate a placement uses is blocked until the application ends.
+type ScaleDownImpact struct {
+	// BlockedNodes are the scale down candidates the placement uses, in order of first use
+	BlockedNodes []string
+	// Pods is the number of drivers and executors placed on BlockedNodes
+	Pods int
+}
+
+// NewestNodes returns ScaleDownCandidates that picks the count most recently created nodes, with ties broken by
+// node name
+func NewestNodes(count int) ScaleDownCandidates {
+	return func(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) map[string]bool {
+		candidates := make(map[string]bool, count)
+		for _, nodeName := range ordering.PriorityOrder(nodesSchedulingMetadata, ordering.NewestFirst) {
+			if len(candidates) >= count {
+				break
+			}
+			candidates[nodeName] = true
+		}
+		return candidates
+	}
+}
+
+// AnnotatedNodes returns ScaleDownCandidates that picks the nodes annotated with key. When value is not empty, the
+// annotation also has to have that value.
+func AnnotatedNodes(key, value string) ScaleDownCandidates {
+	return func(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) map[string]bool {
+		candidates := make(map[string]bool)
+		for nodeName, nodeSchedulingMetadata := range nodesSchedulingMetadata {
+			if v, ok := nodeSchedulingMetadata.Annotations[key]; ok && (value == "" || v == value) {
+				candidates[nodeName] = true
+			}
+		}
+		return candidates
+	}
+}
+
+// EmptierThan returns ScaleDownCandidates that picks the nodes with a dominant utilization below threshold, see
+// ordering.DominantUtilization. A threshold of 0.1 picks nodes that have less than a tenth of every resource in use.
+func EmptierThan(threshold float64) ScaleDownCandidates {
+	return func(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) map[string]bool {
+		candidates := make(map[string]bool)
+		for nodeName, nodeSchedulingMetadata := range nodesSchedulingMetadata {
+			if ordering.DominantUtilization(nodeSchedulingMetadata) < threshold {
+				candidates[nodeName] = true
+			}
+		}
+		return candidates
+	}
+}
+
+// AnyScaleDownCandidates returns ScaleDownCandidates that picks the nodes picked by any of candidates
+func AnyScaleDownCandidates(candidates ...ScaleDownCandidates) ScaleDownCandidates {
+	return func(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) map[string]bool {
+		union := make(map[string]bool)
+		for _, c := range candidates {
+			for nodeName := range c(nodesSchedulingMetadata) {
+				union[nodeName] = true
+			}
+		}
+		return union
+	}
+}
+
+// ScaleDownFriendly returns a SparkBinPackFunction that keeps applications off the nodes picked by candidates, so
+// that the autoscaler can remove them. Both priority orders are sorted by ordering.MostUtilized, keeping the given
+// order for ties, so well used nodes are filled first. binpacker is called without the candidates first, and only
+// when the application does not fit is it called again with as few candidates as needed, most utilized first, at
+// the end of both priority orders. Leaving out the other candidates keeps strategies that do not follow the priority
+// order, e.g. DistributeEvenly, from spreading onto them. The fewest candidates are found with a binary search,
+// which assumes that an application that fits with some candidates also fits with more. When ctx is done before
+// the search ends, the placement found so far is returned with TimedOut set. Placements that use candidates have
+// their ScaleDownImpact set, and ComputeScaleDownImpact finds out which candidates any other placement blocks.
+func ScaleDownFriendly(candidates ScaleDownCandidates, binpacker SparkBinPackFunction) SparkBinPackFunction {
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		scaleDownCandidates := candidates(nodesSchedulingMetadata)
+		driverNodes, driverCandidates := splitScaleDownCandidates(driverNodePriorityOrder, nodesSchedulingMetadata, scaleDownCandidates)
+		executorNodes, executorCandidates := splitScaleDownCandidates(executorNodePriorityOrder, nodesSchedulingMetadata, scaleDownCandidates)
+
+		packingResult := binpacker(ctx, driverResources, executorResources, executorCount, driverNodes, executorNodes, nodesSchedulingMetadata)
+		if packingResult.HasCapacity || packingResult.TimedOut || (len(driverCandidates) == 0 && len(executorCandidates) == 0) {
+			return packingResult
+		}
+
+		_, fallbackCandidates := splitScaleDownCandidates(
+			distinctNodes(append(append([]string{}, executorCandidates...), driverCandidates...)), nodesSchedulingMetadata, scaleDownCandidates)
+		packWithCandidates := func(count int) *PackingResult {
+			allowed := make(map[string]bool, count)
+			for _, nodeName := range fallbackCandidates[:count] {
+				allowed[nodeName] = true
+			}
+			return binpacker(
+				ctx,
+				driverResources,
+				executorResources,
+				executorCount,
+				withAllowedCandidates(driverNodes, driverCandidates, allowed),
+				withAllowedCandidates(executorNodes, executorCandidates, allowed),
+				nodesSchedulingMetadata)
+		}
+
+		packingResult = packWithCandidates(len(fallbackCandidates))
+		if !packingResult.HasCapacity {
+			return packingResult
+		}
+		low, high := 0, len(fallbackCandidates)
+		for high-low > 1 {
+			if isDone(ctx) {
+				// the search was cut short, so the placement uses enough candidates but may not use the fewest
+				packingResult.TimedOut = true
+				break
+			}
+			mid := low + (high-low)/2
+			midResult := packWithCandidates(mid)
+			if midResult.TimedOut {
+				packingResult.TimedOut = true
+				break
+			}
+			if midResult.HasCapacity {
+				high, packingResult = mid, midResult
+			} else {
+				low = mid
+			}
+		}
+		packingResult.ScaleDownImpact = scaleDownImpact(scaleDownCandidates, packingResult)
+		return packingResult
+	})
+}
+
+// withAllowedCandidates returns nodes followed by the candidateNodes that are allowed
+func withAllowedCandidates(nodes, candidateNodes []string, allowed map[string]bool) []string {
+	return append(append(make([]string, 0, len(nodes)+len(allowed)), nodes...), filterNodes(candidateNodes, func(nodeName string) bool {
+		return allowed[nodeName]
+	})...)
+}
+
+// splitScaleDownCandidates returns the nodes of nodePriorityOrder that are not candidates and the ones that are,
+// each sorted by ordering.MostUtilized. Nodes without scheduling metadata are dropped.
+func splitScaleDownCandidates(
+	nodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	candidates map[string]bool) ([]string, []string) {
+
+	var nodes, candidateNodes []ordering.Node
+	for _, nodeName := range nodePriorityOrder {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if !ok {
+			continue
+		}
+		node := ordering.Node{Name: nodeName, NodeSchedulingMetadata: nodeSchedulingMetadata}
+		if candidates[nodeName] {
+			candidateNodes = append(candidateNodes, node)
+		} else {
+			nodes = append(nodes, node)
+		}
+	}
+	return sortedByUtilization(nodes), sortedByUtilization(candidateNodes)
+}
+
+func sortedByUtilization(nodes []ordering.Node) []string {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return ordering.MostUtilized(nodes[i], nodes[j]) < 0
+	})
+	nodeNames := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		nodeNames = append(nodeNames, node.Name)
+	}
+	return nodeNames
+}
+
+// ComputeScaleDownImpact returns the scale down candidates picked by candidates that packingResult uses
+func ComputeScaleDownImpact(
+	candidates ScaleDownCandidates,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	packingResult *PackingResult) *ScaleDownImpact {
+	return scaleDownImpact(candidates(nodesSchedulingMetadata), packingResult)
+}
+
+func scaleDownImpact(scaleDownCandidates map[string]bool, packingResult *PackingResult) *ScaleDownImpact {
+	impact := &ScaleDownImpact{BlockedNodes: make([]string, 0)}
+	blocked := make(map[string]bool)
+	for _, nodeName := range usedNodes(packingResult) {
+		if !scaleDownCandidates[nodeName] {
+			continue
+		}
+		impact.Pods++
+		if !blocked[nodeName] {
+			blocked[nodeName] = true
+			impact.BlockedNodes = append(impact.BlockedNodes, nodeName)
+		}
+	}
+	return impact
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func scaleDownTestNodes() resources.NodeGroupSchedulingMetadata {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	node := func(available int64, age time.Duration, annotations map[string]string) *resources.NodeSchedulingMetadata {
+		nodeSchedulingMetadata := resources.CreateSchedulingMetadataWithTotals(available, 8, available, 8, 0, 0, "zone1")
+		nodeSchedulingMetadata.CreationTimestamp = now.Add(-age)
+		nodeSchedulingMetadata.Annotations = annotations
+		return nodeSchedulingMetadata
+	}
+	return resources.NodeGroupSchedulingMetadata{
+		"full":   node(2, 3*time.Hour, nil),
+		"half":   node(4, 2*time.Hour, nil),
+		"empty":  node(8, time.Hour, map[string]string{"scale-down": "true"}),
+		"newest": node(6, 0, nil),
+	}
+}
+
+func TestScaleDownCandidates(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates ScaleDownCandidates
+		expected   map[string]bool
+	}{{
+		name:       "newest nodes",
+		candidates: NewestNodes(2),
+		expected:   map[string]bool{"newest": true, "empty": true},
+	}, {
+		name:       "annotated nodes",
+		candidates: AnnotatedNodes("scale-down", ""),
+		expected:   map[string]bool{"empty": true},
+	}, {
+		name:       "annotated nodes with a value",
+		candidates: AnnotatedNodes("scale-down", "false"),
+		expected:   map[string]bool{},
+	}, {
+		name:       "emptier than",
+		candidates: EmptierThan(0.3),
+		expected:   map[string]bool{"newest": true, "empty": true},
+	}, {
+		name:       "any",
+		candidates: AnyScaleDownCandidates(AnnotatedNodes("scale-down", ""), NewestNodes(1)),
+		expected:   map[string]bool{"newest": true, "empty": true},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if candidates := test.candidates(scaleDownTestNodes()); !reflect.DeepEqual(test.expected, candidates) {
+				t.Fatalf("mismatch in candidates, expected: %v, got: %v", test.expected, candidates)
+			}
+		})
+	}
+}
+
+func TestScaleDownFriendly(t *testing.T) {
+	nodePriorityOrder := []string{"empty", "newest", "half", "full"}
+	pack := func(executorCount int) *PackingResult {
+		return ScaleDownFriendly(EmptierThan(0.3), TightlyPack)(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			executorCount,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			scaleDownTestNodes())
+	}
+
+	packingResult := pack(3)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if packingResult.DriverNode != "full" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "full", packingResult.DriverNode)
+	}
+	expectedExecutorNodes := []string{"full", "half", "half"}
+	if !reflect.DeepEqual(expectedExecutorNodes, packingResult.ExecutorNodes) {
+		t.Fatalf("mismatch in executor nodes, expected: %v, got: %v", expectedExecutorNodes, packingResult.ExecutorNodes)
+	}
+	expectedImpact := &ScaleDownImpact{BlockedNodes: []string{}}
+	if impact := ComputeScaleDownImpact(EmptierThan(0.3), scaleDownTestNodes(), packingResult); !reflect.DeepEqual(expectedImpact, impact) {
+		t.Fatalf("mismatch in scale down impact, expected: %v, got: %v", expectedImpact, impact)
+	}
+
+	packingResult = pack(10)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if packingResult.DriverNode != "full" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "full", packingResult.DriverNode)
+	}
+	expectedImpact = &ScaleDownImpact{BlockedNodes: []string{"newest"}, Pods: 5}
+	if impact := ComputeScaleDownImpact(EmptierThan(0.3), scaleDownTestNodes(), packingResult); !reflect.DeepEqual(expectedImpact, impact) {
+		t.Fatalf("mismatch in scale down impact, expected: %v, got: %v", expectedImpact, impact)
+	}
+
+	packingResult = pack(20)
+	if packingResult.HasCapacity {
+		t.Fatalf("expected the application not to fit")
+	}
+}
+
+func TestScaleDownFriendlyUsesFewestCandidates(t *testing.T) {
+	candidate := func(cpu int64) *resources.NodeSchedulingMetadata {
+		nodeSchedulingMetadata := resources.CreateSchedulingMetadata(cpu, cpu, 0, "zone1")
+		nodeSchedulingMetadata.Annotations = map[string]string{"scale-down": "true"}
+		return nodeSchedulingMetadata
+	}
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"a": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"b": candidate(1),
+		"c": candidate(1),
+	}
+	nodePriorityOrder := []string{"a", "b", "c"}
+	candidates := AnnotatedNodes("scale-down", "")
+
+	packingResult := ScaleDownFriendly(candidates, DistributeEvenly)(
+		context.Background(),
+		resources.CreateResources(1, 1, 0),
+		resources.CreateResources(1, 1, 0),
+		4,
+		nodePriorityOrder,
+		nodePriorityOrder,
+		nodesSchedulingMetadata)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if packingResult.TimedOut {
+		t.Fatalf("expected packing not to time out")
+	}
+	expectedImpact := &ScaleDownImpact{BlockedNodes: []string{"b"}, Pods: 1}
+	if impact := ComputeScaleDownImpact(candidates, nodesSchedulingMetadata, packingResult); !reflect.DeepEqual(expectedImpact, impact) {
+		t.Fatalf("mismatch in scale down impact, expected: %v, got: %v", expectedImpact, impact)
+	}
+	if !reflect.DeepEqual(expectedImpact, packingResult.ScaleDownImpact) {
+		t.Fatalf("mismatch in attached scale down impact, expected: %v, got: %v", expectedImpact, packingResult.ScaleDownImpact)
+	}
+}
+
+func TestScaleDownFriendlyTimesOutDuringTheSearch(t *testing.T) {
+	candidate := func(cpu int64) *resources.NodeSchedulingMetadata {
+		nodeSchedulingMetadata := resources.CreateSchedulingMetadata(cpu, cpu, 0, "zone1")
+		nodeSchedulingMetadata.Annotations = map[string]string{"scale-down": "true"}
+		return nodeSchedulingMetadata
+	}
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"a": resources.CreateSchedulingMetadata(4, 4, 0, "zone1"),
+		"b": candidate(1),
+		"c": candidate(1),
+	}
+	nodePriorityOrder := []string{"a", "b", "c"}
+	candidates := AnnotatedNodes("scale-down", "")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// ctx is done once the application is packed with every candidate
+	calls := 0
+	cancelAfterFallback := SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+		calls++
+		if calls == 2 {
+			defer cancel()
+		}
+		return DistributeEvenly(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+	})
+
+	packingResult := ScaleDownFriendly(candidates, cancelAfterFallback)(
+		ctx,
+		resources.CreateResources(1, 1, 0),
+		resources.CreateResources(1, 1, 0),
+		4,
+		nodePriorityOrder,
+		nodePriorityOrder,
+		nodesSchedulingMetadata)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+	if !packingResult.TimedOut {
+		t.Fatalf("expected packing to time out")
+	}
+	expectedImpact := &ScaleDownImpact{BlockedNodes: []string{"b", "c"}, Pods: 2}
+	if !reflect.DeepEqual(expectedImpact, packingResult.ScaleDownImpact) {
+		t.Fatalf("mismatch in scale down impact, expected: %v, got: %v", expectedImpact, packingResult.ScaleDownImpact)
+	}
+}
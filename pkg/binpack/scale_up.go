@@ -0,0 +1,195 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	werror "github.com/palantir/witchcraft-go-error"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// VirtualNodeNamePrefix prefixes the names of the nodes added by WithVirtualNodes
+const VirtualNodeNamePrefix = "virtual-node-"
+
+// NodeTemplate describes the nodes an instance group is scaled up with
+type NodeTemplate struct {
+	// Resources are the schedulable resources of a new node, all of which are available
+	Resources *resources.Resources
+	Zone      string
+	Labels    map[string]string
+}
+
+// ScaleUpResult is the outcome of a scale up simulation with one template. NodeCount is the number of nodes of the
+// template that need to be added for the application to fit, and PackingResult is the packing on the existing nodes
+// plus NodeCount virtual nodes.
+type ScaleUpResult struct {
+	// TemplateIndex is the index of the template in the templates passed to SimulateScaleUp, as several templates
+	// can share a zone
+	TemplateIndex int
+	Zone          string
+	NodeCount     int
+	PackingResult *PackingResult
+}
+
+// WithVirtualNodes returns a copy of nodesSchedulingMetadata with count nodes built from template added, and the
+// names of the added nodes. The metadata of existing nodes is shared with nodesSchedulingMetadata.
+func WithVirtualNodes(
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	template NodeTemplate,
+	count int) (resources.NodeGroupSchedulingMetadata, []string) {
+
+	withVirtualNodes := make(resources.NodeGroupSchedulingMetadata, len(nodesSchedulingMetadata)+count)
+	for nodeName, nodeSchedulingMetadata := range nodesSchedulingMetadata {
+		withVirtualNodes[nodeName] = nodeSchedulingMetadata
+	}
+	labels := make(map[string]string, len(template.Labels)+1)
+	for key, value := range template.Labels {
+		labels[key] = value
+	}
+	if _, ok := labels[corev1.LabelTopologyZone]; !ok && template.Zone != "" {
+		labels[corev1.LabelTopologyZone] = template.Zone
+	}
+
+	virtualNodeNames := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		nodeName := fmt.Sprintf("%s%s-%d", VirtualNodeNamePrefix, template.Zone, i)
+		withVirtualNodes[nodeName] = &resources.NodeSchedulingMetadata{
+			AvailableResources:   template.Resources.Copy(),
+			SchedulableResources: template.Resources.Copy(),
+			ZoneLabel:            template.Zone,
+			AllLabels:            labels,
+			Ready:                true,
+		}
+		virtualNodeNames = append(virtualNodeNames, nodeName)
+	}
+	return withVirtualNodes, virtualNodeNames
+}
+
+// SimulateScaleUp finds, for each of templates, the minimum number of nodes built from it that have to be added for
+// binpacker to fit the application. Virtual nodes come after the existing nodes in both priority orders, so the
+// existing capacity is used first. At most maxNodes nodes are added per template, and templates that do not make the
+// application fit with maxNodes nodes are left out of the result, which is sorted by NodeCount, then by zone and
+// then by template index.
+//
+// The minimum is found with a binary search, which assumes that if binpacker fits the application on n new nodes it
+// also fits it on more. This holds for the strategies of this package, including the ones that choose between nodes
+// heuristically, like MinimalFragmentation. Custom strategies that do not satisfy it can pass LinearSearch to try node
+// counts one by one from zero instead. It returns an error when ctx is done before every template is simulated;
+// BestEffortOnTimeout does not apply.
+func SimulateScaleUp(
+	ctx context.Context,
+	binpacker SparkBinPackFunction,
+	driverResources, executorResources *resources.Resources,
+	executorCount int,
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	templates []NodeTemplate,
+	maxNodes int,
+	options ...SearchOption) ([]ScaleUpResult, error) {
+
+	searchOptions := newSearchOptions(options)
+	results := make([]ScaleUpResult, 0, len(templates))
+	for templateIndex, template := range templates {
+		pack := func(count int) (*PackingResult, error) {
+			withVirtualNodes, virtualNodeNames := WithVirtualNodes(nodesSchedulingMetadata, template, count)
+			packingResult := binpacker(
+				ctx,
+				driverResources,
+				executorResources,
+				executorCount,
+				append(append(make([]string, 0, len(driverNodePriorityOrder)+count), driverNodePriorityOrder...), virtualNodeNames...),
+				append(append(make([]string, 0, len(executorNodePriorityOrder)+count), executorNodePriorityOrder...), virtualNodeNames...),
+				withVirtualNodes)
+			if packingResult.TimedOut {
+				return nil, werror.Error("timed out simulating a scale up",
+					werror.SafeParam("templateIndex", templateIndex),
+					werror.SafeParam("nodeCount", count))
+			}
+			return packingResult, nil
+		}
+
+		search := minNodeCountBinarySearch
+		if searchOptions.linearSearch {
+			search = minNodeCountLinearSearch
+		}
+		nodeCount, packingResult, err := search(maxNodes, pack)
+		if err != nil {
+			return nil, err
+		}
+		if packingResult == nil {
+			continue
+		}
+		results = append(results, ScaleUpResult{
+			TemplateIndex: templateIndex,
+			Zone:          template.Zone,
+			NodeCount:     nodeCount,
+			PackingResult: packingResult,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].NodeCount != results[j].NodeCount {
+			return results[i].NodeCount < results[j].NodeCount
+		}
+		if results[i].Zone != results[j].Zone {
+			return results[i].Zone < results[j].Zone
+		}
+		return results[i].TemplateIndex < results[j].TemplateIndex
+	})
+	return results, nil
+}
+
+// minNodeCountBinarySearch returns the smallest count in [0, maxNodes] for which pack fits the application, and its
+// packing result, assuming that pack fits it for any count above one it fits it for. The packing result is nil when
+// the application does not fit with maxNodes nodes.
+func minNodeCountBinarySearch(maxNodes int, pack func(count int) (*PackingResult, error)) (int, *PackingResult, error) {
+	bestResult, err := pack(maxNodes)
+	if err != nil || !bestResult.HasCapacity {
+		return 0, nil, err
+	}
+	// invariant: low nodes do not fit, high nodes do
+	low, high := -1, maxNodes
+	for high-low > 1 {
+		mid := low + (high-low)/2
+		packingResult, err := pack(mid)
+		if err != nil {
+			return 0, nil, err
+		}
+		if packingResult.HasCapacity {
+			high, bestResult = mid, packingResult
+		} else {
+			low = mid
+		}
+	}
+	return high, bestResult, nil
+}
+
+// minNodeCountLinearSearch is like minNodeCountBinarySearch, but tries every count from zero, so that it does not
+// assume anything about pack
+func minNodeCountLinearSearch(maxNodes int, pack func(count int) (*PackingResult, error)) (int, *PackingResult, error) {
+	for count := 0; count <= maxNodes; count++ {
+		packingResult, err := pack(count)
+		if err != nil {
+			return 0, nil, err
+		}
+		if packingResult.HasCapacity {
+			return count, packingResult, nil
+		}
+	}
+	return 0, nil, nil
+}
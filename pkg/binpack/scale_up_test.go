@@ -0,0 +1,286 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestWithVirtualNodes(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(2, 2, 0, "zone1"),
+	}
+	template := NodeTemplate{Resources: resources.CreateResources(4, 4, 0), Zone: "zone2", Labels: map[string]string{"instance-group": "batch"}}
+
+	withVirtualNodes, virtualNodeNames := WithVirtualNodes(nodesSchedulingMetadata, template, 2)
+
+	if len(virtualNodeNames) != 2 {
+		t.Fatalf("expected %v virtual nodes, got: %v", 2, virtualNodeNames)
+	}
+	// the given metadata is not modified
+	if len(nodesSchedulingMetadata) != 1 {
+		t.Fatalf("expected %v nodes, got: %v", 1, nodesSchedulingMetadata)
+	}
+	if len(withVirtualNodes) != 3 {
+		t.Fatalf("expected %v nodes with the virtual nodes, got: %v", 3, withVirtualNodes)
+	}
+	for _, nodeName := range virtualNodeNames {
+		virtualNode := withVirtualNodes[nodeName]
+		if !virtualNode.AvailableResources.Eq(template.Resources) {
+			t.Fatalf("mismatch in available resources, expected: %v, got: %v", template.Resources, virtualNode.AvailableResources)
+		}
+		if virtualNode.ZoneLabel != "zone2" {
+			t.Fatalf("mismatch in zone, expected: %v, got: %v", "zone2", virtualNode.ZoneLabel)
+		}
+		expectedLabels := map[string]string{"instance-group": "batch", corev1.LabelTopologyZone: "zone2"}
+		if !reflect.DeepEqual(expectedLabels, virtualNode.AllLabels) {
+			t.Fatalf("mismatch in labels, expected: %v, got: %v", expectedLabels, virtualNode.AllLabels)
+		}
+	}
+}
+
+func TestSimulateScaleUp(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(2, 2, 0, "zone1"),
+	}
+	nodePriorityOrder := []string{"n1"}
+	templates := []NodeTemplate{
+		{Resources: resources.CreateResources(4, 4, 0), Zone: "zone1"},
+		{Resources: resources.CreateResources(8, 8, 0), Zone: "zone2"},
+	}
+
+	tests := []struct {
+		name               string
+		binpacker          SparkBinPackFunction
+		executorCount      int
+		maxNodes           int
+		expectedZones      []string
+		expectedNodeCounts []int
+	}{{
+		name:               "application that fits needs no nodes",
+		binpacker:          TightlyPack,
+		executorCount:      1,
+		maxNodes:           10,
+		expectedZones:      []string{"zone1", "zone2"},
+		expectedNodeCounts: []int{0, 0},
+	}, {
+		name:               "existing capacity is used in every zone",
+		binpacker:          TightlyPack,
+		executorCount:      10,
+		maxNodes:           10,
+		expectedZones:      []string{"zone2", "zone1"},
+		expectedNodeCounts: []int{2, 3},
+	}, {
+		name:               "single az packing only uses existing capacity in its zone",
+		binpacker:          SingleAZTightlyPack,
+		executorCount:      14,
+		maxNodes:           10,
+		expectedZones:      []string{"zone2", "zone1"},
+		expectedNodeCounts: []int{2, 4},
+	}, {
+		name:               "templates that need more than max nodes are left out",
+		binpacker:          TightlyPack,
+		executorCount:      10,
+		maxNodes:           2,
+		expectedZones:      []string{"zone2"},
+		expectedNodeCounts: []int{2},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			results, err := SimulateScaleUp(
+				context.Background(),
+				test.binpacker,
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				test.executorCount,
+				nodePriorityOrder,
+				nodePriorityOrder,
+				nodesSchedulingMetadata,
+				templates,
+				test.maxNodes)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			zones := make([]string, 0)
+			nodeCounts := make([]int, 0)
+			for _, result := range results {
+				if !result.PackingResult.HasCapacity {
+					t.Fatalf("expected the application to fit")
+				}
+				zones = append(zones, result.Zone)
+				nodeCounts = append(nodeCounts, result.NodeCount)
+			}
+			if !reflect.DeepEqual(test.expectedZones, zones) {
+				t.Fatalf("mismatch in zones, expected: %v, got: %v", test.expectedZones, zones)
+			}
+			if !reflect.DeepEqual(test.expectedNodeCounts, nodeCounts) {
+				t.Fatalf("mismatch in node counts, expected: %v, got: %v", test.expectedNodeCounts, nodeCounts)
+			}
+		})
+	}
+}
+
+func TestSimulateScaleUpTemplatesInTheSameZone(t *testing.T) {
+	templates := []NodeTemplate{
+		{Resources: resources.CreateResources(2, 2, 0), Zone: "zone1"},
+		{Resources: resources.CreateResources(8, 8, 0), Zone: "zone1"},
+	}
+
+	results, err := SimulateScaleUp(
+		context.Background(),
+		TightlyPack,
+		resources.CreateResources(1, 1, 0),
+		resources.CreateResources(1, 1, 0),
+		6,
+		nil,
+		nil,
+		resources.NodeGroupSchedulingMetadata{},
+		templates,
+		10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected %v results, got: %v", 2, results)
+	}
+	if results[0].TemplateIndex != 1 {
+		t.Fatalf("mismatch in template index, expected: %v, got: %v", 1, results[0].TemplateIndex)
+	}
+	if results[0].NodeCount != 1 {
+		t.Fatalf("mismatch in node count, expected: %v, got: %v", 1, results[0].NodeCount)
+	}
+	if results[1].TemplateIndex != 0 {
+		t.Fatalf("mismatch in template index, expected: %v, got: %v", 0, results[1].TemplateIndex)
+	}
+	if results[1].NodeCount != 4 {
+		t.Fatalf("mismatch in node count, expected: %v, got: %v", 4, results[1].NodeCount)
+	}
+}
+
+func TestSimulateScaleUpSingleAZMinimalFragmentation(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(3, 3, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(5, 5, 0, "zone2"),
+	}
+	nodePriorityOrder := []string{"n1", "n2"}
+	templates := []NodeTemplate{
+		{Resources: resources.CreateResources(4, 4, 0), Zone: "zone1"},
+		{Resources: resources.CreateResources(6, 6, 0), Zone: "zone2"},
+	}
+	fits := func(executorCount int, template NodeTemplate, count int) bool {
+		withVirtualNodes, virtualNodeNames := WithVirtualNodes(nodesSchedulingMetadata, template, count)
+		order := append(append([]string{}, nodePriorityOrder...), virtualNodeNames...)
+		return SingleAZMinimalFragmentation(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			executorCount,
+			order,
+			order,
+			withVirtualNodes).HasCapacity
+	}
+
+	for _, executorCount := range []int{1, 5, 9, 17} {
+		results, err := SimulateScaleUp(
+			context.Background(),
+			SingleAZMinimalFragmentation,
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			executorCount,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata,
+			templates,
+			10)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(results) != len(templates) {
+			t.Fatalf("expected a result per template for %d executors, got: %v", executorCount, results)
+		}
+		for _, result := range results {
+			template := templates[result.TemplateIndex]
+			if !fits(executorCount, template, result.NodeCount) {
+				t.Fatalf("%d executors do not fit on %d nodes of template %d", executorCount, result.NodeCount, result.TemplateIndex)
+			}
+			if result.NodeCount > 0 && fits(executorCount, template, result.NodeCount-1) {
+				t.Fatalf("%d executors also fit on %d nodes of template %d", executorCount, result.NodeCount-1, result.TemplateIndex)
+			}
+		}
+	}
+}
+
+func TestSimulateScaleUpLinearSearchFindsTheMinimumOfNonMonotoneStrategies(t *testing.T) {
+	// fits the application on exactly one new node, but not on more
+	onlyOneNewNode := SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+		if len(nodesSchedulingMetadata) != 1 {
+			return EmptyPackingResult()
+		}
+		return TightlyPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata)
+	})
+
+	results, err := SimulateScaleUp(
+		context.Background(),
+		onlyOneNewNode,
+		resources.CreateResources(1, 1, 0),
+		resources.CreateResources(1, 1, 0),
+		2,
+		nil,
+		nil,
+		resources.NodeGroupSchedulingMetadata{},
+		[]NodeTemplate{{Resources: resources.CreateResources(8, 8, 0), Zone: "zone1"}},
+		4,
+		LinearSearch)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected %v results, got: %v", 1, results)
+	}
+	if results[0].NodeCount != 1 {
+		t.Fatalf("mismatch in node count, expected: %v, got: %v", 1, results[0].NodeCount)
+	}
+}
+
+func TestSimulateScaleUpTimesOut(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := SimulateScaleUp(
+		ctx,
+		TightlyPack,
+		resources.CreateResources(1, 1, 0),
+		resources.CreateResources(1, 1, 0),
+		2,
+		nil,
+		nil,
+		resources.NodeGroupSchedulingMetadata{},
+		[]NodeTemplate{{Resources: resources.CreateResources(8, 8, 0), Zone: "zone1"}},
+		4)
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
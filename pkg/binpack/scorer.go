@@ -0,0 +1,243 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"math"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PlacementScorer rates a successful PackingResult of application, higher scores are better
+type PlacementScorer interface {
+	Score(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata, application ApplicationResources, packingResult *PackingResult) float64
+}
+
+// PlacementScorerFunc is an adapter to use an ordinary function as a PlacementScorer
+type PlacementScorerFunc func(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata, application ApplicationResources, packingResult *PackingResult) float64
+
+// Score calls f
+func (f PlacementScorerFunc) Score(nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata, application ApplicationResources, packingResult *PackingResult) float64 {
+	return f(nodesSchedulingMetadata, application, packingResult)
+}
+
+// ApplicationResources are the resources requested by the application a placement is scored for. Each executor
+// requests the resources of the ExecutorGroup at its index in PackingResult.ExecutorProfiles, or of the first
+// ExecutorGroup when ExecutorProfiles is not set.
+type ApplicationResources struct {
+	Driver         *resources.Resources
+	ExecutorGroups []ExecutorGroup
+}
+
+// reservedVectors returns the resources the application reserves on each node packingResult places it on
+func (a ApplicationResources) reservedVectors(dimensions resources.Dimensions, packingResult *PackingResult) map[string]resources.Vector {
+	reserved := make(map[string]resources.Vector)
+	reserve := func(nodeName string, r *resources.Resources) {
+		if _, ok := reserved[nodeName]; !ok {
+			reserved[nodeName] = make(resources.Vector, len(dimensions))
+		}
+		reserved[nodeName].Add(dimensions.Vector(r))
+	}
+	if a.Driver != nil {
+		reserve(packingResult.DriverNode, a.Driver)
+	}
+	for i, nodeName := range packingResult.ExecutorNodes {
+		profile := 0
+		if i < len(packingResult.ExecutorProfiles) {
+			profile = packingResult.ExecutorProfiles[i]
+		}
+		if profile < len(a.ExecutorGroups) {
+			reserve(nodeName, a.ExecutorGroups[profile].Resources)
+		}
+	}
+	return reserved
+}
+
+// AvgPackingEfficiencyScorer scores a placement by the average packing efficiency of the driver and executor
+// nodes, see AvgPackingEfficiency.LessThan. This is the default scorer.
+var AvgPackingEfficiencyScorer = PlacementScorerFunc(func(
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	_ ApplicationResources,
+	packingResult *PackingResult) float64 {
+	return usedNodesAvgPackingEfficiency(nodesSchedulingMetadata, packingResult).Max
+})
+
+// ExtendedAvgPackingEfficiencyScorer returns a PlacementScorer that is like AvgPackingEfficiencyScorer, but also
+// accounts for the packing efficiency of the given extended resources, see PackingEfficiency.MaxIncluding
+func ExtendedAvgPackingEfficiencyScorer(extendedResourceNames ...corev1.ResourceName) PlacementScorer {
+	return PlacementScorerFunc(func(
+		_ resources.NodeGroupSchedulingMetadata,
+		_ ApplicationResources,
+		packingResult *PackingResult) float64 {
+		nodeNames := usedNodes(packingResult)
+		sum := 0.0
+		for _, nodeName := range nodeNames {
+			if efficiency, ok := packingResult.PackingEfficiencies[nodeName]; ok && efficiency != nil {
+				sum += efficiency.MaxIncluding(extendedResourceNames...)
+			}
+		}
+		return sum / float64(len(nodeNames))
+	})
+}
+
+// DominantResourceShareScorer scores a placement by the dominant share of the application on the nodes it uses,
+// i.e. the highest fraction of the schedulable CPU, memory or GPU of those nodes that the application reserves.
+// It prefers placements where the application takes up most of the nodes it lands on, leaving other nodes alone.
+var DominantResourceShareScorer = PlacementScorerFunc(func(
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	application ApplicationResources,
+	packingResult *PackingResult) float64 {
+	dimensions := resources.DimensionsOf()
+	reservedByNode := application.reservedVectors(dimensions, packingResult)
+	// summed as floats because schedulable resources can be as large as math.MaxInt64
+	reserved := make([]float64, len(dimensions))
+	total := make([]float64, len(dimensions))
+	for _, nodeName := range distinctNodes(usedNodes(packingResult)) {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if !ok {
+			continue
+		}
+		schedulable := dimensions.Vector(nodeSchedulingMetadata.SchedulableResources)
+		nodeReserved, hasReserved := reservedByNode[nodeName]
+		for i := range dimensions {
+			if hasReserved {
+				reserved[i] += float64(nodeReserved[i])
+			}
+			total[i] += float64(schedulable[i])
+		}
+	}
+	dominantShare := 0.0
+	for i := range total {
+		if total[i] > 0 {
+			dominantShare = math.Max(dominantShare, reserved[i]/total[i])
+		}
+	}
+	return dominantShare
+})
+
+// NodesTouchedScorer scores a placement by the number of distinct nodes it uses, preferring placements that use
+// fewer nodes
+var NodesTouchedScorer = PlacementScorerFunc(func(
+	_ resources.NodeGroupSchedulingMetadata,
+	_ ApplicationResources,
+	packingResult *PackingResult) float64 {
+	return -float64(len(distinctNodes(usedNodes(packingResult))))
+})
+
+// StrandedResourcesScorer scores a placement by how balanced the usage of CPU and memory is on the nodes it uses.
+// On a node where one resource is used up much more than the other, the remainder of the other resource is
+// stranded because nothing can be scheduled to use it. Placements that strand less are preferred.
+var StrandedResourcesScorer = PlacementScorerFunc(func(
+	_ resources.NodeGroupSchedulingMetadata,
+	_ ApplicationResources,
+	packingResult *PackingResult) float64 {
+	nodeNames := distinctNodes(usedNodes(packingResult))
+	stranded := 0.0
+	for _, nodeName := range nodeNames {
+		if efficiency, ok := packingResult.PackingEfficiencies[nodeName]; ok && efficiency != nil {
+			stranded += math.Abs(efficiency.CPU - efficiency.Memory)
+		}
+	}
+	return -stranded / math.Max(float64(len(nodeNames)), 1)
+})
+
+// ZoneWeightScorer returns a PlacementScorer that prefers zones with higher weights. A placement is scored by the
+// weight of the zone of its driver and each executor, averaged. Zones without a weight have a weight of zero.
+func ZoneWeightScorer(zoneWeights map[string]float64) PlacementScorer {
+	return PlacementScorerFunc(func(
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		_ ApplicationResources,
+		packingResult *PackingResult) float64 {
+		nodeNames := usedNodes(packingResult)
+		weight := 0.0
+		for _, nodeName := range nodeNames {
+			if nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]; ok {
+				weight += zoneWeights[nodeSchedulingMetadata.ZoneLabel]
+			}
+		}
+		return weight / float64(len(nodeNames))
+	})
+}
+
+// NodeCost returns the cost of running a node, e.g. its hourly price
+type NodeCost func(nodeName string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) float64
+
+// NodeCostScorer returns a PlacementScorer that prefers placements with a lower total cost of the distinct nodes
+// they use
+func NodeCostScorer(nodeCost NodeCost) PlacementScorer {
+	return PlacementScorerFunc(func(
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		_ ApplicationResources,
+		packingResult *PackingResult) float64 {
+		cost := 0.0
+		for _, nodeName := range distinctNodes(usedNodes(packingResult)) {
+			if nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]; ok {
+				cost += nodeCost(nodeName, nodeSchedulingMetadata)
+			}
+		}
+		return -cost
+	})
+}
+
+// WeightedPlacementScorer is a PlacementScorer with the weight of its scores in a WeightedScorer
+type WeightedPlacementScorer struct {
+	Scorer PlacementScorer
+	Weight float64
+}
+
+// WeightedScorer returns a PlacementScorer that scores a placement by the weighted sum of the scores of scorers.
+// Scorers have different ranges, so weights should account for the range of each scorer.
+func WeightedScorer(scorers ...WeightedPlacementScorer) PlacementScorer {
+	return PlacementScorerFunc(func(
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		application ApplicationResources,
+		packingResult *PackingResult) float64 {
+		score := 0.0
+		for _, scorer := range scorers {
+			score += scorer.Weight * scorer.Scorer.Score(nodesSchedulingMetadata, application, packingResult)
+		}
+		return score
+	})
+}
+
+func usedNodes(packingResult *PackingResult) []string {
+	return append([]string{packingResult.DriverNode}, packingResult.ExecutorNodes...)
+}
+
+func distinctNodes(nodeNames []string) []string {
+	seen := make(map[string]bool, len(nodeNames))
+	distinct := make([]string, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		if !seen[nodeName] {
+			seen[nodeName] = true
+			distinct = append(distinct, nodeName)
+		}
+	}
+	return distinct
+}
+
+// usedNodesAvgPackingEfficiency averages the packing efficiencies of the driver node and of the node of every
+// executor, so nodes with more executors weigh more
+func usedNodesAvgPackingEfficiency(
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	packingResult *PackingResult) AvgPackingEfficiency {
+	nodeNames := usedNodes(packingResult)
+	nodePackingEfficiencies := make([]*PackingEfficiency, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		nodePackingEfficiencies = append(nodePackingEfficiencies, packingResult.PackingEfficiencies[nodeName])
+	}
+	return ComputeAvgPackingEfficiency(nodesSchedulingMetadata, nodePackingEfficiencies)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestPlacementScorers(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"large": resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone-a"),
+		"small": resources.CreateSchedulingMetadataWithTotals(4, 4, 6, 6, 0, 0, "zone-b"),
+	})
+	application := ApplicationResources{
+		Driver:         resources.CreateResources(1, 1, 0),
+		ExecutorGroups: []ExecutorGroup{{Resources: resources.CreateResources(1, 2, 0), Count: 2}},
+	}
+	pack := func(driverNode, executorNode string) *PackingResult {
+		p := TightlyPack(
+			context.Background(),
+			application.Driver,
+			application.ExecutorGroups[0].Resources,
+			application.ExecutorGroups[0].Count,
+			[]string{driverNode},
+			[]string{executorNode},
+			nodesSchedulingMetadata)
+		if !p.HasCapacity {
+			t.Fatalf("expected the application to fit")
+		}
+		return p
+	}
+	onLarge := pack("large", "large")
+	onSmall := pack("small", "small")
+	spread := pack("small", "large")
+
+	tests := []struct {
+		name           string
+		scorer         PlacementScorer
+		expectedScores map[*PackingResult]float64
+	}{{
+		name:           "avg packing efficiency",
+		scorer:         AvgPackingEfficiencyScorer,
+		expectedScores: map[*PackingResult]float64{onLarge: 0.5, onSmall: 5.0 / 6, spread: 0.35},
+	}, {
+		name:           "dominant resource share",
+		scorer:         DominantResourceShareScorer,
+		expectedScores: map[*PackingResult]float64{onLarge: 0.5, onSmall: 5.0 / 6, spread: 5.0 / 16},
+	}, {
+		name:           "nodes touched",
+		scorer:         NodesTouchedScorer,
+		expectedScores: map[*PackingResult]float64{onLarge: -1, onSmall: -1, spread: -2},
+	}, {
+		name:           "stranded resources",
+		scorer:         StrandedResourcesScorer,
+		expectedScores: map[*PackingResult]float64{onLarge: -0.2, onSmall: -1.0 / 12, spread: -(1.0/12 + 0.2) / 2},
+	}, {
+		name:           "zone weights",
+		scorer:         ZoneWeightScorer(map[string]float64{"zone-a": 1}),
+		expectedScores: map[*PackingResult]float64{onLarge: 1, onSmall: 0, spread: 2.0 / 3},
+	}, {
+		name: "node cost",
+		scorer: NodeCostScorer(func(_ string, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) float64 {
+			return float64(nodeSchedulingMetadata.SchedulableResources.CPU.Value())
+		}),
+		expectedScores: map[*PackingResult]float64{onLarge: -10, onSmall: -4, spread: -14},
+	}, {
+		name: "weighted",
+		scorer: WeightedScorer(
+			WeightedPlacementScorer{Scorer: NodesTouchedScorer, Weight: 1},
+			WeightedPlacementScorer{Scorer: ZoneWeightScorer(map[string]float64{"zone-a": 1}), Weight: 2}),
+		expectedScores: map[*PackingResult]float64{onLarge: 1, onSmall: -1, spread: -2.0 / 3},
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for packingResult, expectedScore := range test.expectedScores {
+				if score := test.scorer.Score(nodesSchedulingMetadata, application, packingResult); math.Abs(score-expectedScore) > 1e-9 {
+					t.Fatalf("mismatch in score of driver on %s, executors on %v, expected: %v, got: %v",
+						packingResult.DriverNode, packingResult.ExecutorNodes, expectedScore, score)
+				}
+			}
+		})
+	}
+}
+
+func TestDominantResourceShareScorerUsesApplicationResources(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"node": resources.CreateSchedulingMetadataWithTotals(10, 10, 100, 100, 0, 0, "zone-a"),
+	})
+	driverResources := resources.CreateResources(0, 1, 0)
+	driverResources.CPU = resource.MustParse("100m")
+	executorResources := resources.CreateResources(0, 1, 0)
+	executorResources.CPU = resource.MustParse("200m")
+	application := ApplicationResources{
+		Driver:         driverResources,
+		ExecutorGroups: []ExecutorGroup{{Resources: executorResources, Count: 2}},
+	}
+	packingResult := TightlyPack(
+		context.Background(), driverResources, executorResources, 2, []string{"node"}, []string{"node"}, nodesSchedulingMetadata)
+	if !packingResult.HasCapacity {
+		t.Fatalf("expected the application to fit")
+	}
+
+	// 500m of 10 CPUs, which packing efficiencies round up to a whole CPU
+	if score := DominantResourceShareScorer.Score(nodesSchedulingMetadata, application, packingResult); math.Abs(score-0.05) > 1e-9 {
+		t.Fatalf("mismatch in score, expected: %v, got: %v", 0.05, score)
+	}
+}
+
+func TestChooseBestResult(t *testing.T) {
+	first := &PackingResult{DriverNode: "first", HasCapacity: true}
+	second := &PackingResult{DriverNode: "second", HasCapacity: true}
+	constant := func(score float64) PlacementScorer {
+		return PlacementScorerFunc(func(resources.NodeGroupSchedulingMetadata, ApplicationResources, *PackingResult) float64 {
+			return score
+		})
+	}
+
+	// ties go to the earlier result, whether scores are positive, zero or negative
+	for _, score := range []float64{1, 0, -1} {
+		if chooseBestResult(nil, []*PackingResult{first, second}, constant(score), ApplicationResources{}) != first {
+			t.Fatalf("expected the first result to win a tie at score %v", score)
+		}
+	}
+	prefersSecond := PlacementScorerFunc(func(_ resources.NodeGroupSchedulingMetadata, _ ApplicationResources, p *PackingResult) float64 {
+		if p == second {
+			return -1
+		}
+		return -2
+	})
+	if chooseBestResult(nil, []*PackingResult{first, second}, prefersSecond, ApplicationResources{}) != second {
+		t.Fatalf("expected the second result to win")
+	}
+}
+
+func TestSingleAZWithScorer(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"large": resources.CreateSchedulingMetadataWithTotals(10, 10, 10, 10, 0, 0, "zone-a"),
+		"small": resources.CreateSchedulingMetadataWithTotals(4, 4, 4, 4, 0, 0, "zone-b"),
+	})
+	nodePriorityOrder := []string{"small", "large"}
+	pack := func(binpacker SparkBinPackFunction) *PackingResult {
+		return binpacker(
+			context.Background(),
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			2,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata)
+	}
+
+	if driverNode := pack(SingleAZ(TightlyPackExecutors, nil)).DriverNode; driverNode != "small" {
+		t.Fatalf("mismatch in driver node, expected: %v, got: %v", "small", driverNode)
+	}
+	if driverNode := pack(SingleAZ(TightlyPackExecutors, ZoneWeightScorer(map[string]float64{"zone-a": 1}))).DriverNode; driverNode != "large" {
+		t.Fatalf("mismatch in driver node with zone weights, expected: %v, got: %v", "large", driverNode)
+	}
+}
@@ -16,11 +16,35 @@ package binpack
 
 import (
 	"context"
+	"runtime"
+	"sync"
 
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
 )
 
+// getSingleAZSparkBinFunction packs zones on up to GOMAXPROCS goroutines, as the GenericBinPackFunctions of this
+// package and AvgPackingEfficiencyScorer are safe for concurrent use
 func getSingleAZSparkBinFunction(fn GenericBinPackFunction) SparkBinPackFunction {
+	return ParallelSingleAZ(fn, AvgPackingEfficiencyScorer, runtime.GOMAXPROCS(0))
+}
+
+// SingleAZ returns a SparkBinPackFunction that packs the application into each zone in turn with
+// distributeExecutors, and returns the placement with the highest score. Ties go to the zone of the driver
+// node with the higher priority. A nil scorer defaults to AvgPackingEfficiencyScorer. When ctx is done before all
+// zones are evaluated, see BestEffortOnTimeout.
+func SingleAZ(distributeExecutors GenericBinPackFunction, scorer PlacementScorer, options ...SearchOption) SparkBinPackFunction {
+	return ParallelSingleAZ(distributeExecutors, scorer, 1, options...)
+}
+
+// ParallelSingleAZ is like SingleAZ, but packs up to maxWorkers zones concurrently. The result is the same as
+// the one of SingleAZ, as long as distributeExecutors and scorer are safe for concurrent use. SingleAZ packs zones
+// one at a time, as it may be given functions that are not, while the single-AZ strategies of this package, such as
+// SingleAZTightlyPack, pack zones concurrently.
+func ParallelSingleAZ(distributeExecutors GenericBinPackFunction, scorer PlacementScorer, maxWorkers int, options ...SearchOption) SparkBinPackFunction {
+	if scorer == nil {
+		scorer = AvgPackingEfficiencyScorer
+	}
+	searchOptions := newSearchOptions(options)
 	return SparkBinPackFunction(func(
 		ctx context.Context,
 		driverResources, executorResources *resources.Resources,
@@ -28,30 +52,127 @@ func getSingleAZSparkBinFunction(fn GenericBinPackFunction) SparkBinPackFunction
 		driverNodePriorityOrder, executorNodePriorityOrder []string,
 		nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
 
-		driverZonesInOrder, driverNodePriorityOrderByZone := groupNodesByZone(driverNodePriorityOrder, nodeGroupSchedulingMetadata)
-		_, executorNodePriorityOrderByZone := groupNodesByZone(executorNodePriorityOrder, nodeGroupSchedulingMetadata)
+		pack := func(driverNodePriorityOrder, executorNodePriorityOrder []string) *PackingResult {
+			return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodeGroupSchedulingMetadata, distributeExecutors)
+		}
+		application := ApplicationResources{Driver: driverResources, ExecutorGroups: []ExecutorGroup{{Resources: executorResources, Count: executorCount}}}
+		return packEachDomain(
+			driverNodePriorityOrder, executorNodePriorityOrder, nodeGroupSchedulingMetadata, groupNodesByZone, pack, scorer, application, searchOptions, maxWorkers)
+	})
+}
 
-		packingResults := make([]*PackingResult, 0)
+// getSingleAZMultiProfileSparkBinFunction is the MultiProfileSparkBinPackFunction counterpart of
+// getSingleAZSparkBinFunction, packing zones on up to GOMAXPROCS goroutines
+func getSingleAZMultiProfileSparkBinFunction(fn GenericBinPackFunction) MultiProfileSparkBinPackFunction {
+	return ParallelSingleAZMultiProfile(fn, AvgPackingEfficiencyScorer, runtime.GOMAXPROCS(0))
+}
 
-		for _, zone := range driverZonesInOrder {
-			driverNodePriorityOrderForZone := driverNodePriorityOrderByZone[zone]
-			executorNodePriorityOrderForZone, ok := executorNodePriorityOrderByZone[zone]
-			if !ok {
-				continue
-			}
-			packingResult := SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrderForZone, executorNodePriorityOrderForZone, nodeGroupSchedulingMetadata, fn)
-			// consider all AZs
-			if packingResult.HasCapacity {
-				packingResults = append(packingResults, packingResult)
-			}
+// SingleAZMultiProfile is the MultiProfileSparkBinPackFunction counterpart of SingleAZ. It packs all executor
+// groups into each zone in turn with SparkBinPackMultiProfile, and returns the placement with the highest score.
+func SingleAZMultiProfile(distributeExecutors GenericBinPackFunction, scorer PlacementScorer, options ...SearchOption) MultiProfileSparkBinPackFunction {
+	return ParallelSingleAZMultiProfile(distributeExecutors, scorer, 1, options...)
+}
+
+// ParallelSingleAZMultiProfile is like SingleAZMultiProfile, but packs up to maxWorkers zones concurrently, see
+// ParallelSingleAZ
+func ParallelSingleAZMultiProfile(distributeExecutors GenericBinPackFunction, scorer PlacementScorer, maxWorkers int, options ...SearchOption) MultiProfileSparkBinPackFunction {
+	if scorer == nil {
+		scorer = AvgPackingEfficiencyScorer
+	}
+	searchOptions := newSearchOptions(options)
+	return MultiProfileSparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources *resources.Resources,
+		executorGroups []ExecutorGroup,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		pack := func(driverNodePriorityOrder, executorNodePriorityOrder []string) *PackingResult {
+			return SparkBinPackMultiProfile(ctx, driverResources, executorGroups, driverNodePriorityOrder, executorNodePriorityOrder, nodeGroupSchedulingMetadata, distributeExecutors)
 		}
+		application := ApplicationResources{Driver: driverResources, ExecutorGroups: executorGroups}
+		return packEachDomain(
+			driverNodePriorityOrder, executorNodePriorityOrder, nodeGroupSchedulingMetadata, groupNodesByZone, pack, scorer, application, searchOptions, maxWorkers)
+	})
+}
+
+// packEachDomain calls pack with the nodes of each domain returned by groupNodes on their own, and returns the
+// placement with the highest score, or EmptyPackingResult when the application does not fit into any domain
+func packEachDomain(
+	driverNodePriorityOrder, executorNodePriorityOrder []string,
+	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	groupNodes func([]string, resources.NodeGroupSchedulingMetadata) ([]string, map[string][]string),
+	pack func(driverNodePriorityOrder, executorNodePriorityOrder []string) *PackingResult,
+	scorer PlacementScorer,
+	application ApplicationResources,
+	searchOptions searchOptions,
+	maxWorkers int) *PackingResult {
 
-		if len(packingResults) == 0 {
-			return EmptyPackingResult()
+	driverDomainsInOrder, driverNodePriorityOrderByDomain := groupNodes(driverNodePriorityOrder, nodeGroupSchedulingMetadata)
+	_, executorNodePriorityOrderByDomain := groupNodes(executorNodePriorityOrder, nodeGroupSchedulingMetadata)
+
+	domains := make([]string, 0, len(driverDomainsInOrder))
+	for _, domain := range driverDomainsInOrder {
+		if _, ok := executorNodePriorityOrderByDomain[domain]; ok {
+			domains = append(domains, domain)
 		}
+	}
 
-		return chooseBestResult(nodeGroupSchedulingMetadata, packingResults)
+	// every domain is packed independently, only sharing read-only inputs, and results are kept in domain order
+	// so that ties are broken the same way regardless of which domain finishes first
+	domainPackingResults := make([]*PackingResult, len(domains))
+	forEachConcurrently(len(domains), maxWorkers, func(i int) {
+		domainPackingResults[i] = pack(driverNodePriorityOrderByDomain[domains[i]], executorNodePriorityOrderByDomain[domains[i]])
 	})
+
+	packingResults := make([]*PackingResult, 0)
+
+	for _, packingResult := range domainPackingResults {
+		if packingResult.TimedOut {
+			var bestResult *PackingResult
+			if len(packingResults) > 0 {
+				bestResult = chooseBestResult(nodeGroupSchedulingMetadata, packingResults, scorer, application)
+			}
+			return searchOptions.timedOut(bestResult)
+		}
+		if packingResult.HasCapacity {
+			packingResults = append(packingResults, packingResult)
+		}
+	}
+
+	if len(packingResults) == 0 {
+		return EmptyPackingResult()
+	}
+
+	return chooseBestResult(nodeGroupSchedulingMetadata, packingResults, scorer, application)
+}
+
+// forEachConcurrently calls fn for every index in [0, n) on up to maxWorkers goroutines, and returns once all
+// calls returned. With a single worker fn is called in order on the calling goroutine.
+func forEachConcurrently(n, maxWorkers int, fn func(i int)) {
+	if maxWorkers <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+	indexes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	for w := 0; w < minInt(maxWorkers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 func groupNodesByZone(nodeNames []string, nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) ([]string, map[string][]string) {
@@ -71,25 +192,23 @@ func groupNodesByZone(nodeNames []string, nodesSchedulingMetadata resources.Node
 	return zonesInOrder, nodeNamesByZone
 }
 
-// Chooses the result with the highest avg packing efficiency for the nodes we're scheduling onto.
+// Chooses the result with the highest score for the nodes we're scheduling onto. Ties go to the earlier result,
+// and the first result is chosen even when its score is not positive, as scores of scorers like
+// NodesTouchedScorer always are.
 func chooseBestResult(
 	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
-	results []*PackingResult) *PackingResult {
+	results []*PackingResult,
+	scorer PlacementScorer,
+	application ApplicationResources) *PackingResult {
 
 	bestResult := EmptyPackingResult()
-	bestAvgPackingEfficiency := WorstAvgPackingEfficiency()
-
-	for _, result := range results {
-		nodeNames := append([]string{result.DriverNode}, result.ExecutorNodes...)
-		nodePackingEfficiencies := make([]*PackingEfficiency, 0)
-		for _, nodeName := range nodeNames {
-			nodePackingEfficiency := result.PackingEfficiencies[nodeName]
-			nodePackingEfficiencies = append(nodePackingEfficiencies, nodePackingEfficiency)
-		}
-		avgPackingEfficiency := ComputeAvgPackingEfficiency(nodeGroupSchedulingMetadata, nodePackingEfficiencies)
-		if bestAvgPackingEfficiency.LessThan(avgPackingEfficiency) {
+	bestScore := 0.0
+
+	for i, result := range results {
+		score := scorer.Score(nodeGroupSchedulingMetadata, application, result)
+		if i == 0 || score > bestScore {
 			bestResult = result
-			bestAvgPackingEfficiency = avgPackingEfficiency
+			bestScore = score
 		}
 	}
 
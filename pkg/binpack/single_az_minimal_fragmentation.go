@@ -18,3 +18,7 @@ package binpack
 // when multiple nodes can be used to fit n executors, it will pick the node with the least available resources
 // that still fit n executors, if there are multiple, it will prefer the higher priority node
 var SingleAZMinimalFragmentation = getSingleAZSparkBinFunction(minimalFragmentation)
+
+// SingleAZMinimalFragmentationMultiProfile is a MultiProfileSparkBinPackFunction that places each executor group like
+// SingleAZMinimalFragmentation, with all groups in the same AZ
+var SingleAZMinimalFragmentationMultiProfile = getSingleAZMultiProfileSparkBinFunction(minimalFragmentation)
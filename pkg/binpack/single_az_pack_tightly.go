@@ -19,3 +19,7 @@ package binpack
 // while also ensuring that we can fit everything in a single AZ.
 // If it cannot fit into a single AZ binpacking fails
 var SingleAZTightlyPack = getSingleAZSparkBinFunction(tightlyPackExecutors)
+
+// SingleAZTightlyPackMultiProfile is a MultiProfileSparkBinPackFunction that places each executor group like
+// SingleAZTightlyPack, with all groups in the same AZ
+var SingleAZTightlyPackMultiProfile = getSingleAZMultiProfileSparkBinFunction(tightlyPackExecutors)
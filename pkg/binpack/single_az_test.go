@@ -0,0 +1,97 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestParallelSingleAZ(t *testing.T) {
+	nodesSchedulingMetadata := make(resources.NodeGroupSchedulingMetadata)
+	nodePriorityOrder := make([]string, 0)
+	for i := 0; i < 48; i++ {
+		nodeName := fmt.Sprintf("n%d", i)
+		// zones of equally sized nodes tie, every third zone packs tighter
+		available := int64(8)
+		if i%3 == 0 {
+			available = 4
+		}
+		nodesSchedulingMetadata[nodeName] = resources.CreateSchedulingMetadataWithTotals(available, 8, available, 8, 0, 0, fmt.Sprintf("zone%d", i%12))
+		nodePriorityOrder = append(nodePriorityOrder, nodeName)
+	}
+
+	for _, distributeExecutors := range []struct {
+		name string
+		fn   GenericBinPackFunction
+	}{
+		{"tightly pack", TightlyPackExecutors},
+		{"minimal fragmentation", MinimalFragmentationExecutors},
+	} {
+		for _, executorCount := range []int{1, 4, 10, 40} {
+			t.Run(fmt.Sprintf("%s %d executors", distributeExecutors.name, executorCount), func(t *testing.T) {
+				pack := func(binpacker SparkBinPackFunction) *PackingResult {
+					return binpacker(
+						context.Background(),
+						resources.CreateResources(1, 1, 0),
+						resources.CreateResources(1, 1, 0),
+						executorCount,
+						nodePriorityOrder,
+						nodePriorityOrder,
+						nodesSchedulingMetadata)
+				}
+				expected := pack(SingleAZ(distributeExecutors.fn, nil))
+				for i := 0; i < 10; i++ {
+					p := pack(ParallelSingleAZ(distributeExecutors.fn, nil, 4))
+					if p.HasCapacity != expected.HasCapacity {
+						t.Fatalf("mismatch in willFit, expected: %v, got: %v", expected.HasCapacity, p.HasCapacity)
+					}
+					if p.DriverNode != expected.DriverNode {
+						t.Fatalf("mismatch in driver node, expected: %v, got: %v", expected.DriverNode, p.DriverNode)
+					}
+					if !reflect.DeepEqual(expected.ExecutorNodes, p.ExecutorNodes) {
+						t.Fatalf("mismatch in executor nodes, expected: %v, got: %v", expected.ExecutorNodes, p.ExecutorNodes)
+					}
+				}
+
+				packMultiProfile := func(binpacker MultiProfileSparkBinPackFunction) *PackingResult {
+					return binpacker(
+						context.Background(),
+						resources.CreateResources(1, 1, 0),
+						[]ExecutorGroup{
+							{Resources: resources.CreateResources(1, 1, 0), Count: executorCount},
+							{Resources: resources.CreateResources(2, 2, 0), Count: 1},
+						},
+						nodePriorityOrder,
+						nodePriorityOrder,
+						nodesSchedulingMetadata)
+				}
+				expected = packMultiProfile(SingleAZMultiProfile(distributeExecutors.fn, nil))
+				for i := 0; i < 10; i++ {
+					p := packMultiProfile(ParallelSingleAZMultiProfile(distributeExecutors.fn, nil, 4))
+					if p.HasCapacity != expected.HasCapacity || p.DriverNode != expected.DriverNode ||
+						!reflect.DeepEqual(expected.ExecutorNodes, p.ExecutorNodes) ||
+						!reflect.DeepEqual(expected.ExecutorProfiles, p.ExecutorProfiles) {
+						t.Fatalf("mismatch in multi profile placement, expected: %+v, got: %+v", expected, p)
+					}
+				}
+			})
+		}
+	}
+}
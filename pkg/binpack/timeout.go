@@ -0,0 +1,109 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// SearchOption configures a strategy that searches through several placements, such as SingleAZ, BestDriver,
+// Elastic and SimulateScaleUp
+type SearchOption func(*searchOptions)
+
+type searchOptions struct {
+	bestEffortOnTimeout bool
+	linearSearch        bool
+}
+
+// BestEffortOnTimeout is a SearchOption that makes a strategy return the best placement it found so far when ctx is
+// done, instead of TimedOutPackingResult. Such placements are valid but may not be the best ones, and have TimedOut
+// set.
+var BestEffortOnTimeout SearchOption = func(options *searchOptions) {
+	options.bestEffortOnTimeout = true
+}
+
+// LinearSearch is a SearchOption that makes SimulateScaleUp try node counts one by one from zero instead of
+// searching them with a binary search. It is only needed for custom strategies that may fit an application on n new
+// nodes but not on more, and packs the application up to maxNodes+1 times per template.
+var LinearSearch SearchOption = func(options *searchOptions) {
+	options.linearSearch = true
+}
+
+func newSearchOptions(options []SearchOption) searchOptions {
+	var o searchOptions
+	for _, option := range options {
+		option(&o)
+	}
+	return o
+}
+
+// isDone returns true once ctx is cancelled or past its deadline, without blocking
+func isDone(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// observedContext is a context that records whether it was seen done, through Done or Err, by whoever checks it.
+// A strategy that gave up because its context was done has seen it done, so a failed attempt that did not see it
+// done failed because the application does not fit, even when the context is done by the time the attempt returns.
+type observedContext struct {
+	context.Context
+	sawDone int32
+}
+
+func observeContext(ctx context.Context) *observedContext {
+	return &observedContext{Context: ctx}
+}
+
+// Done returns the channel of the wrapped context, recording whether it is already closed
+func (c *observedContext) Done() <-chan struct{} {
+	done := c.Context.Done()
+	select {
+	case <-done:
+		atomic.StoreInt32(&c.sawDone, 1)
+	default:
+	}
+	return done
+}
+
+// Err returns the error of the wrapped context, recording whether it is done
+func (c *observedContext) Err() error {
+	err := c.Context.Err()
+	if err != nil {
+		atomic.StoreInt32(&c.sawDone, 1)
+	}
+	return err
+}
+
+// interrupted returns true once the context was seen done
+func (c *observedContext) interrupted() bool {
+	return atomic.LoadInt32(&c.sawDone) == 1
+}
+
+// timedOut returns the result of a search that was interrupted because ctx is done, given the best placement
+// found so far, which may be nil
+func (o searchOptions) timedOut(bestResult *PackingResult) *PackingResult {
+	if bestResult == nil || !o.bestEffortOnTimeout {
+		return TimedOutPackingResult()
+	}
+	bestEffortResult := *bestResult
+	bestEffortResult.TimedOut = true
+	return &bestEffortResult
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestStrategiesTimeOut(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(8, 8, 0, "zone2"),
+	})
+	nodePriorityOrder := []string{"n1", "n2"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, strategy := range Strategies() {
+		t.Run(strategy.Name, func(t *testing.T) {
+			p := strategy.Function(
+				ctx,
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				2,
+				nodePriorityOrder,
+				nodePriorityOrder,
+				nodesSchedulingMetadata)
+			if !p.TimedOut {
+				t.Fatalf("expected packing to time out")
+			}
+			if p.HasCapacity {
+				t.Fatalf("expected the application not to fit")
+			}
+			if p.Explanation != nil {
+				t.Fatalf("expected no explanation, got: %v", p.Explanation)
+			}
+		})
+	}
+}
+
+func TestBestEffortOnTimeout(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(8, 8, 0, "zone2"),
+	})
+	nodePriorityOrder := []string{"n1", "n2"}
+
+	tests := []struct {
+		name               string
+		bestEffort         bool
+		willFit            bool
+		expectedDriverNode string
+	}{{
+		name:    "returns a timed out result by default",
+		willFit: false,
+	}, {
+		name:               "returns the best placement found so far",
+		bestEffort:         true,
+		willFit:            true,
+		expectedDriverNode: "n1",
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var options []SearchOption
+			if test.bestEffort {
+				options = append(options, BestEffortOnTimeout)
+			}
+			// times out after the first zone is packed
+			cancelAfterPacking := GenericBinPackFunction(func(
+				ctx context.Context,
+				itemResources *resources.Resources,
+				itemCount int,
+				nodePriorityOrder []string,
+				nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+				reservedResources *resources.Ledger) ([]string, bool) {
+				defer cancel()
+				return TightlyPackExecutors(ctx, itemResources, itemCount, nodePriorityOrder, nodesSchedulingMetadata, reservedResources)
+			})
+
+			p := SingleAZ(cancelAfterPacking, nil, options...)(
+				ctx,
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				2,
+				nodePriorityOrder,
+				nodePriorityOrder,
+				nodesSchedulingMetadata)
+			if !p.TimedOut {
+				t.Fatalf("expected packing to time out")
+			}
+			if p.HasCapacity != test.willFit {
+				t.Fatalf("mismatch in willFit, expected: %v, got: %v", test.willFit, p.HasCapacity)
+			}
+			if p.DriverNode != test.expectedDriverNode {
+				t.Fatalf("mismatch in driver node, expected: %v, got: %v", test.expectedDriverNode, p.DriverNode)
+			}
+		})
+	}
+}
+
+func TestFailureAfterDeadlineIsNotTimedOut(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(8, 8, 0, "zone1"),
+	})
+	nodePriorityOrder := []string{"n1"}
+
+	for _, checksContext := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		// the application does not fit, and ctx is done by the time the search finishes
+		failThenCancel := GenericBinPackFunction(func(
+			ctx context.Context,
+			itemResources *resources.Resources,
+			itemCount int,
+			nodePriorityOrder []string,
+			nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+			reservedResources *resources.Ledger) ([]string, bool) {
+			cancel()
+			if checksContext {
+				isDone(ctx)
+			}
+			return nil, false
+		})
+
+		p := SparkBinPack(
+			ctx,
+			resources.CreateResources(1, 1, 0),
+			resources.CreateResources(1, 1, 0),
+			2,
+			nodePriorityOrder,
+			nodePriorityOrder,
+			nodesSchedulingMetadata,
+			failThenCancel)
+		if p.HasCapacity || p.TimedOut != checksContext {
+			t.Fatalf("expected timed out to be %v, got: %+v", checksContext, p)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"strings"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+// TopologyHierarchy lists the node label keys of nested topology domains, from the widest to the narrowest, e.g.
+// a zone label followed by a rack label. Label values are read from NodeSchedulingMetadata.AllLabels.
+type TopologyHierarchy []string
+
+// TopologyDomain returns the domain of a node at a level of the hierarchy, where level 0 is the widest. Domains are
+// identified by the values of all labels up to and including level, so that e.g. racks with the same name in
+// different zones are different domains. Nodes without any of these labels are not part of a domain at level. Level
+// -1 is the whole cluster, and no node has a domain at a level outside of the hierarchy.
+func (h TopologyHierarchy) TopologyDomain(level int, nodeSchedulingMetadata *resources.NodeSchedulingMetadata) (string, bool) {
+	if level < -1 || level >= len(h) {
+		return "", false
+	}
+	values := make([]string, 0, level+1)
+	for _, labelKey := range h[:level+1] {
+		value, ok := nodeSchedulingMetadata.AllLabels[labelKey]
+		if !ok {
+			return "", false
+		}
+		values = append(values, value)
+	}
+	// label values can not contain slashes
+	return strings.Join(values, "/"), true
+}
+
+// GroupNodes groups nodeNames by their domain at a level of the hierarchy. Domains are returned in the order they
+// first appear in, and nodes keep their order within a domain. Nodes without a domain at level are left out.
+func (h TopologyHierarchy) GroupNodes(
+	level int,
+	nodeNames []string,
+	nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) ([]string, map[string][]string) {
+	domainsInOrder := make([]string, 0)
+	nodeNamesByDomain := make(map[string][]string)
+	for _, nodeName := range nodeNames {
+		nodeSchedulingMetadata, ok := nodesSchedulingMetadata[nodeName]
+		if !ok {
+			continue
+		}
+		domain, ok := h.TopologyDomain(level, nodeSchedulingMetadata)
+		if !ok {
+			continue
+		}
+		if _, ok := nodeNamesByDomain[domain]; !ok {
+			domainsInOrder = append(domainsInOrder, domain)
+		}
+		nodeNamesByDomain[domain] = append(nodeNamesByDomain[domain], nodeName)
+	}
+	return domainsInOrder, nodeNamesByDomain
+}
+
+// SmallestTopologyDomain returns a SparkBinPackFunction that tries to fit the application into a single domain of
+// the narrowest level of hierarchy, then widens to the next level up whenever no domain of a level fits, and finally
+// to the whole cluster. Within a level, it chooses between domains like SingleAZ does between zones. A nil scorer
+// defaults to AvgPackingEfficiencyScorer.
+func SmallestTopologyDomain(
+	hierarchy TopologyHierarchy, distributeExecutors GenericBinPackFunction, scorer PlacementScorer, options ...SearchOption) SparkBinPackFunction {
+	if scorer == nil {
+		scorer = AvgPackingEfficiencyScorer
+	}
+	searchOptions := newSearchOptions(options)
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+
+		pack := func(driverNodePriorityOrder, executorNodePriorityOrder []string) *PackingResult {
+			return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, distributeExecutors)
+		}
+		application := ApplicationResources{Driver: driverResources, ExecutorGroups: []ExecutorGroup{{Resources: executorResources, Count: executorCount}}}
+		for level := len(hierarchy) - 1; level >= 0; level-- {
+			groupNodes := func(nodeNames []string, nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) ([]string, map[string][]string) {
+				return hierarchy.GroupNodes(level, nodeNames, nodesSchedulingMetadata)
+			}
+			packingResult := packEachDomain(
+				driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, groupNodes, pack, scorer, application, searchOptions, 1)
+			if packingResult.HasCapacity || packingResult.TimedOut {
+				return packingResult
+			}
+		}
+		return pack(driverNodePriorityOrder, executorNodePriorityOrder)
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testZoneLabel = "topology.kubernetes.io/zone"
+	testRackLabel = "example.com/rack"
+)
+
+func nodeInRack(cpu int64, zone, rack string) *resources.NodeSchedulingMetadata {
+	n := resources.CreateSchedulingMetadata(cpu, cpu, 0, zone)
+	n.AllLabels = map[string]string{testZoneLabel: zone}
+	if rack != "" {
+		n.AllLabels[testRackLabel] = rack
+	}
+	return n
+}
+
+func TestTopologyHierarchy(t *testing.T) {
+	hierarchy := TopologyHierarchy{testZoneLabel, testRackLabel}
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": nodeInRack(1, "zone1", "rack1"),
+		"n2": nodeInRack(1, "zone2", "rack1"),
+		"n3": nodeInRack(1, "zone1", "rack1"),
+		"n4": nodeInRack(1, "zone1", ""),
+	})
+
+	domains, nodesByDomain := hierarchy.GroupNodes(1, []string{"n1", "n2", "n3", "n4"}, nodesSchedulingMetadata)
+	assert.Equal(t, []string{"zone1/rack1", "zone2/rack1"}, domains)
+	assert.Equal(t, map[string][]string{"zone1/rack1": {"n1", "n3"}, "zone2/rack1": {"n2"}}, nodesByDomain)
+
+	domains, _ = hierarchy.GroupNodes(0, []string{"n1", "n2", "n3", "n4"}, nodesSchedulingMetadata)
+	assert.Equal(t, []string{"zone1", "zone2"}, domains)
+
+	for _, level := range []int{-2, 2} {
+		domain, ok := hierarchy.TopologyDomain(level, nodesSchedulingMetadata["n1"])
+		assert.False(t, ok, "level %d", level)
+		assert.Equal(t, "", domain)
+	}
+}
+
+func TestSmallestTopologyDomain(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": nodeInRack(2, "zone1", "rack1"),
+		"n2": nodeInRack(3, "zone1", "rack2"),
+		"n3": nodeInRack(3, "zone1", "rack2"),
+		"n4": nodeInRack(4, "zone2", "rack3"),
+	})
+	nodePriorityOrder := []string{"n1", "n2", "n3", "n4"}
+	binpacker := SmallestTopologyDomain(TopologyHierarchy{testZoneLabel, testRackLabel}, TightlyPackExecutors, nil)
+
+	tests := []struct {
+		name                  string
+		executorCount         int
+		willFit               bool
+		expectedDriverNode    string
+		expectedExecutorNodes []string
+	}{{
+		name:                  "fits into a single rack",
+		executorCount:         4,
+		willFit:               true,
+		expectedDriverNode:    "n2",
+		expectedExecutorNodes: []string{"n2", "n2", "n3", "n3"},
+	}, {
+		name:                  "widens to a zone",
+		executorCount:         6,
+		willFit:               true,
+		expectedDriverNode:    "n1",
+		expectedExecutorNodes: []string{"n1", "n2", "n2", "n2", "n3", "n3"},
+	}, {
+		name:                  "widens to the cluster",
+		executorCount:         10,
+		willFit:               true,
+		expectedDriverNode:    "n1",
+		expectedExecutorNodes: []string{"n1", "n2", "n2", "n2", "n3", "n3", "n3", "n4", "n4", "n4"},
+	}, {
+		name:          "does not fit",
+		executorCount: 12,
+		willFit:       false,
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := binpacker(
+				context.Background(),
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				test.executorCount,
+				nodePriorityOrder,
+				nodePriorityOrder,
+				nodesSchedulingMetadata)
+			require.Equal(t, test.willFit, p.HasCapacity)
+			assert.Equal(t, test.expectedDriverNode, p.DriverNode)
+			if test.willFit {
+				assert.Equal(t, test.expectedExecutorNodes, p.ExecutorNodes)
+			}
+		})
+	}
+}
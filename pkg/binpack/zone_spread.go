@@ -0,0 +1,119 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+// ZoneSpreadTightlyPack is a SparkBinPackFunction that tries to put the driver pod to as prior nodes as possible
+// and then spreads executors across zones, with at most one executor of difference between zones. Within a zone,
+// executors are tightly packed.
+var ZoneSpreadTightlyPack = ZoneSpread(TightlyPackExecutors, 1)
+
+// ZoneSpread returns a SparkBinPackFunction that places the driver like SparkBinPack and spreads executors across
+// the zones of the executor nodes, see SpreadAcrossZones
+func ZoneSpread(perZone GenericBinPackFunction, maxSkew int) SparkBinPackFunction {
+	spread := SpreadAcrossZones(perZone, maxSkew)
+	return SparkBinPackFunction(func(
+		ctx context.Context,
+		driverResources, executorResources *resources.Resources,
+		executorCount int,
+		driverNodePriorityOrder, executorNodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata) *PackingResult {
+		return SparkBinPack(ctx, driverResources, executorResources, executorCount, driverNodePriorityOrder, executorNodePriorityOrder, nodesSchedulingMetadata, spread)
+	})
+}
+
+// SpreadAcrossZones returns a GenericBinPackFunction that spreads items across zones such that the number of items
+// in any two zones differs by at most maxSkew, like a Kubernetes topology spread constraint on the zone label.
+// Every zone of a node in nodePriorityOrder counts, including zones without any capacity left, which then limit all
+// other zones to maxSkew items. Items are assigned to zones by filling the zone with the fewest items first, with
+// ties going to the zone that comes first in nodePriorityOrder, and are then placed on the nodes of each zone with
+// perZone. A maxSkew below one is treated as one.
+func SpreadAcrossZones(perZone GenericBinPackFunction, maxSkew int) GenericBinPackFunction {
+	if maxSkew < 1 {
+		maxSkew = 1
+	}
+	return GenericBinPackFunction(func(
+		ctx context.Context,
+		itemResources *resources.Resources,
+		itemCount int,
+		nodePriorityOrder []string,
+		nodesSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+		reservedResources *resources.Ledger) ([]string, bool) {
+		if itemCount == 0 {
+			return []string{}, true
+		}
+		zonesInOrder, nodePriorityOrderByZone := groupNodesByZone(nodePriorityOrder, nodesSchedulingMetadata)
+		zoneCapacities := make([]int, len(zonesInOrder))
+		nodeCapacity := executorCapacity(nodesSchedulingMetadata, reservedResources, itemResources)
+		for i, zone := range zonesInOrder {
+			for _, nodeName := range distinctNodes(nodePriorityOrderByZone[zone]) {
+				zoneCapacities[i] += nodeCapacity(nodeName)
+			}
+		}
+		zoneCounts, ok := spreadCounts(itemCount, zoneCapacities, maxSkew)
+		if !ok {
+			return nil, false
+		}
+
+		// zones reserve on a snapshot, so nothing needs to be released when a later zone does not fit
+		zoneReservedResources := reservedResources.Snapshot()
+		nodes := make([]string, 0, itemCount)
+		for i, zone := range zonesInOrder {
+			if zoneCounts[i] == 0 {
+				continue
+			}
+			zoneNodes, ok := perZone(ctx, itemResources, zoneCounts[i], nodePriorityOrderByZone[zone], nodesSchedulingMetadata, zoneReservedResources)
+			if !ok {
+				return nil, false
+			}
+			nodes = append(nodes, zoneNodes...)
+		}
+		reservedResources.Merge(zoneReservedResources)
+		return nodes, true
+	})
+}
+
+// spreadCounts assigns itemCount items to zones one at a time, always to the zone with the fewest items that has
+// capacity left and would not exceed maxSkew
+func spreadCounts(itemCount int, zoneCapacities []int, maxSkew int) ([]int, bool) {
+	zoneCounts := make([]int, len(zoneCapacities))
+	for placed := 0; placed < itemCount; placed++ {
+		minCount := -1
+		for _, count := range zoneCounts {
+			if minCount < 0 || count < minCount {
+				minCount = count
+			}
+		}
+		next := -1
+		for i, count := range zoneCounts {
+			if count >= zoneCapacities[i] || count+1-minCount > maxSkew {
+				continue
+			}
+			if next < 0 || count < zoneCounts[next] {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil, false
+		}
+		zoneCounts[next]++
+	}
+	return zoneCounts, true
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binpack
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
+)
+
+func TestZoneSpread(t *testing.T) {
+	nodesSchedulingMetadata := resources.NodeGroupSchedulingMetadata(map[string]*resources.NodeSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(11, 11, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(5, 5, 0, "zone2"),
+		"n3": resources.CreateSchedulingMetadata(5, 5, 0, "zone2"),
+		"n4": resources.CreateSchedulingMetadata(1, 1, 0, "zone3"),
+	})
+	nodePriorityOrder := []string{"n1", "n2", "n3", "n4"}
+
+	tests := []struct {
+		name           string
+		binpacker      SparkBinPackFunction
+		executorCount  int
+		willFit        bool
+		expectedCounts map[string]int
+	}{{
+		name:           "spreads executors evenly across zones",
+		binpacker:      ZoneSpreadTightlyPack,
+		executorCount:  5,
+		willFit:        true,
+		expectedCounts: map[string]int{"n1": 2, "n2": 2, "n4": 1},
+	}, {
+		name:          "does not fit when a full zone would exceed the max skew",
+		binpacker:     ZoneSpreadTightlyPack,
+		executorCount: 6,
+		willFit:       false,
+	}, {
+		name:           "allows a larger max skew",
+		binpacker:      ZoneSpread(TightlyPackExecutors, 2),
+		executorCount:  6,
+		willFit:        true,
+		expectedCounts: map[string]int{"n1": 3, "n2": 2, "n4": 1},
+	}, {
+		name:           "uses the per zone packer",
+		binpacker:      ZoneSpread(DistributeExecutorsEvenly, 5),
+		executorCount:  8,
+		willFit:        true,
+		expectedCounts: map[string]int{"n1": 4, "n2": 2, "n3": 1, "n4": 1},
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.binpacker(
+				context.Background(),
+				resources.CreateResources(1, 1, 0),
+				resources.CreateResources(1, 1, 0),
+				test.executorCount,
+				nodePriorityOrder,
+				nodePriorityOrder,
+				nodesSchedulingMetadata)
+			if p.HasCapacity != test.willFit {
+				t.Fatalf("mismatch in willFit, expected: %v, got: %v", test.willFit, p.HasCapacity)
+			}
+			if !test.willFit {
+				return
+			}
+			if p.DriverNode != "n1" {
+				t.Fatalf("mismatch in driver node, expected: %v, got: %v", "n1", p.DriverNode)
+			}
+			counts := make(map[string]int)
+			for _, n := range p.ExecutorNodes {
+				counts[n]++
+			}
+			if !reflect.DeepEqual(test.expectedCounts, counts) {
+				t.Fatalf("mismatch in executor counts, expected: %v, got: %v", test.expectedCounts, counts)
+			}
+		})
+	}
+}
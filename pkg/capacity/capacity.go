@@ -19,6 +19,7 @@ import (
 
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
 	"gopkg.in/inf.v0"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
@@ -71,29 +72,100 @@ func GetNodeCapacity(available, reserved, singleExecutor *resources.Resources) i
 		singleExecutor.NvidiaGPU,
 	)
 
-	return min(capacityConsideringCPUOnly, capacityConsideringMemoryOnly, capacityConsideringNvidiaGPUOnly)
+	nodeCapacity := min(capacityConsideringCPUOnly, capacityConsideringMemoryOnly, capacityConsideringNvidiaGPUOnly)
+	for name, required := range singleExecutor.Extended {
+		capacityConsideringResourceOnly := getCapacityAgainstSingleDimension(available.Get(name), reserved.Get(name), required)
+		if capacityConsideringResourceOnly < nodeCapacity {
+			nodeCapacity = capacityConsideringResourceOnly
+		}
+	}
+	return nodeCapacity
 }
 
-// GetNodeCapacities return value is ordered according to nodePriorityOrder
+// GetNodeCapacityVector returns how many singleExecutor can fit within available - reserved. It is equivalent to
+// GetNodeCapacity, but runs on int64 arithmetic only.
+func GetNodeCapacityVector(available, reserved, singleExecutor resources.Vector) int {
+	nodeCapacity := math.MaxInt
+	for i := range singleExecutor {
+		if reserved[i] > available[i] {
+			return 0
+		}
+		if singleExecutor[i] <= 0 {
+			continue
+		}
+		if capacityConsideringDimensionOnly := (available[i] - reserved[i]) / singleExecutor[i]; capacityConsideringDimensionOnly < int64(nodeCapacity) {
+			nodeCapacity = int(capacityConsideringDimensionOnly)
+		}
+	}
+	return nodeCapacity
+}
+
+// GetLimitingResources returns the resources that limit how many singleExecutor fit within available - reserved,
+// i.e. the resources GetNodeCapacity is bound by, in the order of resources.DimensionsOf
+func GetLimitingResources(available, reserved, singleExecutor *resources.Resources) []corev1.ResourceName {
+	dimensions := resources.DimensionsOf(singleExecutor)
+	availableVector := dimensions.Vector(available)
+	reservedVector := dimensions.Vector(reserved)
+	singleExecutorVector := dimensions.Vector(singleExecutor)
+	nodeCapacity := int64(GetNodeCapacityVector(availableVector, reservedVector, singleExecutorVector))
+
+	limitingResources := make([]corev1.ResourceName, 0)
+	for i, name := range dimensions {
+		if reservedVector[i] > availableVector[i] ||
+			(singleExecutorVector[i] > 0 && (availableVector[i]-reservedVector[i])/singleExecutorVector[i] == nodeCapacity) {
+			limitingResources = append(limitingResources, name)
+		}
+	}
+	return limitingResources
+}
+
+// GetNodeCapacities return value is ordered according to nodePriorityOrder. See GetNodeCapacitiesWithMaxPerNode for
+// capacities clipped to a per application limit of executors per node.
 func GetNodeCapacities(
 	nodePriorityOrder []string,
 	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
 	reservedResources resources.NodeGroupResources,
 	singleExecutor *resources.Resources,
 ) []NodeAndExecutorCapacity {
-	capacities := make([]NodeAndExecutorCapacity, 0, len(nodePriorityOrder))
+	return GetNodeCapacitiesFromLedger(nodePriorityOrder, nodeGroupSchedulingMetadata, resources.NewLedger(reservedResources), singleExecutor)
+}
 
-	for _, nodeName := range nodePriorityOrder {
-		if nodeSchedulingMetadata, ok := nodeGroupSchedulingMetadata[nodeName]; ok {
-			reserved := resources.Zero()
+// GetNodeCapacitiesWithMaxPerNode is like GetNodeCapacities, but clips the capacity of every node to
+// maxExecutorsPerNode, unless it is not positive
+func GetNodeCapacitiesWithMaxPerNode(
+	nodePriorityOrder []string,
+	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources resources.NodeGroupResources,
+	singleExecutor *resources.Resources,
+	maxExecutorsPerNode int,
+) []NodeAndExecutorCapacity {
+	capacities := GetNodeCapacities(nodePriorityOrder, nodeGroupSchedulingMetadata, reservedResources, singleExecutor)
+	if maxExecutorsPerNode <= 0 {
+		return capacities
+	}
+	for i := range capacities {
+		if capacities[i].Capacity > maxExecutorsPerNode {
+			capacities[i].Capacity = maxExecutorsPerNode
+		}
+	}
+	return capacities
+}
 
-			if alreadyReserved, ok := reservedResources[nodeName]; ok {
-				reserved = alreadyReserved
-			}
+// GetNodeCapacitiesFromLedger is like GetNodeCapacities, but takes the resources already reserved from a ledger
+func GetNodeCapacitiesFromLedger(
+	nodePriorityOrder []string,
+	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	singleExecutor *resources.Resources,
+) []NodeAndExecutorCapacity {
+	capacities := make([]NodeAndExecutorCapacity, 0, len(nodePriorityOrder))
+	nodeCapacity := GetNodeCapacityFuncFromLedger(nodeGroupSchedulingMetadata, reservedResources, singleExecutor)
 
+	for _, nodeName := range nodePriorityOrder {
+		if _, ok := nodeGroupSchedulingMetadata[nodeName]; ok {
 			capacities = append(capacities, NodeAndExecutorCapacity{
 				nodeName,
-				GetNodeCapacity(nodeSchedulingMetadata.AvailableResources, reserved, singleExecutor),
+				nodeCapacity(nodeName),
 			})
 		}
 	}
@@ -101,6 +173,39 @@ func GetNodeCapacities(
 	return capacities
 }
 
+// GetNodeCapacityFunc returns a function that computes how many singleExecutor fit on a node, given the resources
+// already reserved on it in reservedResources. Nodes without scheduling metadata have no capacity.
+func GetNodeCapacityFunc(
+	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources resources.NodeGroupResources,
+	singleExecutor *resources.Resources,
+) func(nodeName string) int {
+	return GetNodeCapacityFuncFromLedger(nodeGroupSchedulingMetadata, resources.NewLedger(reservedResources), singleExecutor)
+}
+
+// GetNodeCapacityFuncFromLedger is like GetNodeCapacityFunc, but takes the resources already reserved from a ledger,
+// including its tentative reservations. The returned function reuses its vectors across calls, and is not safe for
+// concurrent use.
+func GetNodeCapacityFuncFromLedger(
+	nodeGroupSchedulingMetadata resources.NodeGroupSchedulingMetadata,
+	reservedResources *resources.Ledger,
+	singleExecutor *resources.Resources,
+) func(nodeName string) int {
+	dimensions := resources.DimensionsOf(singleExecutor)
+	singleExecutorVector := dimensions.Vector(singleExecutor)
+	available := make(resources.Vector, len(dimensions))
+	reserved := make(resources.Vector, len(dimensions))
+	return func(nodeName string) int {
+		nodeSchedulingMetadata, ok := nodeGroupSchedulingMetadata[nodeName]
+		if !ok {
+			return 0
+		}
+		dimensions.SetVector(available, nodeSchedulingMetadata.AvailableResources)
+		reservedResources.SetReservedVector(dimensions, nodeName, reserved)
+		return GetNodeCapacityVector(available, reserved, singleExecutorVector)
+	}
+}
+
 // FilterOutNodesWithoutCapacity returns a slice of nodes with non-zero capacity
 func FilterOutNodesWithoutCapacity(capacities []NodeAndExecutorCapacity) []NodeAndExecutorCapacity {
 	filteredCapacities := make([]NodeAndExecutorCapacity, 0, len(capacities))
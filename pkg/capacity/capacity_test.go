@@ -20,6 +20,7 @@ import (
 
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/resources"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
@@ -115,6 +116,37 @@ func TestGetNodeCapacity(t *testing.T) {
 		},
 		singleExecutor: singleExecutor,
 		expected:       0,
+	}, {
+		name: "capacity is limited by an extended resource",
+		available: &resources.Resources{
+			CPU:       *resource.NewQuantity(4, resource.DecimalSI),
+			Memory:    *resource.NewQuantity(4, resource.DecimalSI),
+			NvidiaGPU: *resource.NewQuantity(4, resource.DecimalSI),
+			Extended: map[corev1.ResourceName]resource.Quantity{
+				corev1.ResourceEphemeralStorage: *resource.NewQuantity(20, resource.BinarySI),
+			},
+		},
+		reserved: resources.Zero(),
+		singleExecutor: &resources.Resources{
+			CPU:       *resource.NewQuantity(1, resource.DecimalSI),
+			Memory:    *resource.NewQuantity(1, resource.DecimalSI),
+			NvidiaGPU: *resource.NewQuantity(1, resource.DecimalSI),
+			Extended: map[corev1.ResourceName]resource.Quantity{
+				corev1.ResourceEphemeralStorage: *resource.NewQuantity(10, resource.BinarySI),
+			},
+		},
+		expected: 2,
+	}, {
+		name:      "does not fit when an extended resource is missing",
+		available: singleExecutor,
+		reserved:  resources.Zero(),
+		singleExecutor: &resources.Resources{
+			CPU: *resource.NewQuantity(1, resource.DecimalSI),
+			Extended: map[corev1.ResourceName]resource.Quantity{
+				"example.com/fpga": *resource.NewQuantity(1, resource.DecimalSI),
+			},
+		},
+		expected: 0,
 	},
 	}
 
@@ -124,3 +156,48 @@ func TestGetNodeCapacity(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNodeCapacities(t *testing.T) {
+	nodeGroupSchedulingMetadata := resources.NodeGroupSchedulingMetadata{
+		"n1": resources.CreateSchedulingMetadata(10, 10, 0, "zone1"),
+		"n2": resources.CreateSchedulingMetadata(2, 2, 0, "zone1"),
+	}
+	reservedResources := resources.NodeGroupResources{"n1": resources.CreateResources(1, 1, 0)}
+	singleExecutor := resources.CreateResources(1, 1, 0)
+
+	assert.Equal(t,
+		[]NodeAndExecutorCapacity{{"n1", 9}, {"n2", 2}},
+		GetNodeCapacities([]string{"n1", "n2", "n3"}, nodeGroupSchedulingMetadata, reservedResources, singleExecutor))
+	assert.Equal(t,
+		[]NodeAndExecutorCapacity{{"n1", 3}, {"n2", 2}},
+		GetNodeCapacitiesWithMaxPerNode([]string{"n1", "n2", "n3"}, nodeGroupSchedulingMetadata, reservedResources, singleExecutor, 3))
+	assert.Equal(t,
+		[]NodeAndExecutorCapacity{{"n1", math.MaxInt}},
+		GetNodeCapacitiesWithMaxPerNode([]string{"n1"}, nodeGroupSchedulingMetadata, reservedResources, resources.Zero(), 0))
+	assert.Equal(t,
+		[]NodeAndExecutorCapacity{{"n1", 3}},
+		GetNodeCapacitiesWithMaxPerNode([]string{"n1"}, nodeGroupSchedulingMetadata, reservedResources, resources.Zero(), 3))
+	assert.Equal(t, 9, GetNodeCapacityFunc(nodeGroupSchedulingMetadata, reservedResources, singleExecutor)("n1"))
+
+	ledger := resources.NewLedger(reservedResources)
+	ledger.Reserve("n2", resources.CreateResources(1, 1, 0))
+	assert.Equal(t,
+		[]NodeAndExecutorCapacity{{"n1", 9}, {"n2", 1}},
+		GetNodeCapacitiesFromLedger([]string{"n1", "n2", "n3"}, nodeGroupSchedulingMetadata, ledger, singleExecutor))
+	assert.Equal(t, 0, GetNodeCapacityFuncFromLedger(nodeGroupSchedulingMetadata, ledger, singleExecutor)("n3"))
+}
+
+func TestGetLimitingResources(t *testing.T) {
+	available := resources.CreateResources(8, 4, 0)
+	singleExecutor := resources.CreateResources(2, 2, 0)
+
+	assert.Equal(t,
+		[]corev1.ResourceName{corev1.ResourceMemory},
+		GetLimitingResources(available, resources.Zero(), singleExecutor))
+	assert.Equal(t,
+		[]corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory},
+		GetLimitingResources(available, resources.CreateResources(4, 0, 0), singleExecutor))
+	assert.Equal(t,
+		[]corev1.ResourceName{"nvidia.com/gpu"},
+		GetLimitingResources(available, resources.Zero(), resources.CreateResources(1, 1, 1)))
+}
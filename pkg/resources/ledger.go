@@ -0,0 +1,263 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Ledger keeps track of the resources reserved on each node during binpacking. Reservations are tentative until
+// they are committed, and tentative reservations can be rolled back. Reservations are kept as int64 values like
+// Vector, so that reserving and looking up resources in tight loops avoids resource.Quantity arithmetic.
+//
+// A snapshot of a ledger is a new ledger layered on top of it, so placements can be tried out on a snapshot
+// without copying any reservations and without changing the original ledger. A ledger must not be changed while
+// snapshots of it are in use. A ledger is not safe for concurrent use, but snapshots of the same ledger can be
+// used concurrently.
+type Ledger struct {
+	base      *Ledger
+	committed map[string]*nodeAmounts
+	tentative map[string]*nodeAmounts
+}
+
+// nodeAmounts holds the resources reserved on one node, with CPU, Memory and NvidiaGPU stored like the
+// first three dimensions of a Vector
+type nodeAmounts struct {
+	core     [3]int64
+	extended map[corev1.ResourceName]int64
+}
+
+func (r *nodeAmounts) add(dimensions Dimensions, v Vector, n int) {
+	for i := range r.core {
+		r.core[i] = addInt64(r.core[i], mulInt64(v[i], int64(n)))
+	}
+	for i := 3; i < len(dimensions); i++ {
+		if r.extended == nil {
+			r.extended = make(map[corev1.ResourceName]int64, len(dimensions)-3)
+		}
+		r.extended[dimensions[i]] = addInt64(r.extended[dimensions[i]], mulInt64(v[i], int64(n)))
+	}
+}
+
+func (r *nodeAmounts) addTo(dimensions Dimensions, v Vector) {
+	for i := range r.core {
+		v[i] = addInt64(v[i], r.core[i])
+	}
+	for i := 3; i < len(dimensions); i++ {
+		v[i] = addInt64(v[i], r.extended[dimensions[i]])
+	}
+}
+
+func (r *nodeAmounts) merge(other *nodeAmounts) {
+	for i := range r.core {
+		r.core[i] = addInt64(r.core[i], other.core[i])
+	}
+	for name, value := range other.extended {
+		if r.extended == nil {
+			r.extended = make(map[corev1.ResourceName]int64, len(other.extended))
+		}
+		r.extended[name] = addInt64(r.extended[name], value)
+	}
+}
+
+func (r *nodeAmounts) dimensions() Dimensions {
+	dimensions := DimensionsOf()
+	for name := range r.extended {
+		dimensions = append(dimensions, name)
+	}
+	extended := dimensions[3:]
+	sort.Slice(extended, func(i, j int) bool {
+		return extended[i] < extended[j]
+	})
+	return dimensions
+}
+
+func (r *nodeAmounts) resources() *Resources {
+	dimensions := r.dimensions()
+	v := make(Vector, len(dimensions))
+	r.addTo(dimensions, v)
+	return dimensions.Resources(v)
+}
+
+func reserve(reservations map[string]*nodeAmounts, nodeName string, dimensions Dimensions, v Vector, n int) {
+	r, ok := reservations[nodeName]
+	if !ok {
+		r = &nodeAmounts{}
+		reservations[nodeName] = r
+	}
+	r.add(dimensions, v, n)
+}
+
+func mergeReservations(into, from map[string]*nodeAmounts) {
+	for nodeName, r := range from {
+		existing, ok := into[nodeName]
+		if !ok {
+			existing = &nodeAmounts{}
+			into[nodeName] = existing
+		}
+		existing.merge(r)
+	}
+}
+
+// NewLedger creates a ledger with reserved as its committed reservations. reserved is copied and may be nil.
+func NewLedger(reserved NodeGroupResources) *Ledger {
+	ledger := &Ledger{
+		committed: make(map[string]*nodeAmounts, len(reserved)),
+		tentative: make(map[string]*nodeAmounts),
+	}
+	for nodeName, r := range reserved {
+		dimensions := DimensionsOf(r)
+		reserve(ledger.committed, nodeName, dimensions, dimensions.Vector(r), 1)
+	}
+	return ledger
+}
+
+// Snapshot returns a new ledger with all reservations of the receiver, committed or not, as its base
+func (l *Ledger) Snapshot() *Ledger {
+	return &Ledger{
+		base:      l,
+		committed: make(map[string]*nodeAmounts),
+		tentative: make(map[string]*nodeAmounts),
+	}
+}
+
+// Reserve tentatively reserves resources on a node
+func (l *Ledger) Reserve(nodeName string, resources *Resources) {
+	dimensions := DimensionsOf(resources)
+	reserve(l.tentative, nodeName, dimensions, dimensions.Vector(resources), 1)
+}
+
+// ReserveVector tentatively reserves the resources of v, with the given dimensions, on a node
+func (l *Ledger) ReserveVector(dimensions Dimensions, nodeName string, v Vector) {
+	reserve(l.tentative, nodeName, dimensions, v, 1)
+}
+
+// ReserveVectorTimes tentatively reserves the resources of v, with the given dimensions, n times on a node
+func (l *Ledger) ReserveVectorTimes(dimensions Dimensions, nodeName string, v Vector, n int) {
+	reserve(l.tentative, nodeName, dimensions, v, n)
+}
+
+// ReserveAll tentatively reserves resources on each node in reserved
+func (l *Ledger) ReserveAll(reserved NodeGroupResources) {
+	for nodeName, r := range reserved {
+		l.Reserve(nodeName, r)
+	}
+}
+
+// Merge tentatively reserves the reservations made on snapshot, committed or not, which must be a snapshot of the
+// receiver
+func (l *Ledger) Merge(snapshot *Ledger) {
+	mergeReservations(l.tentative, snapshot.committed)
+	mergeReservations(l.tentative, snapshot.tentative)
+}
+
+// Commit makes all tentative reservations permanent
+func (l *Ledger) Commit() {
+	if len(l.committed) == 0 {
+		l.committed = l.tentative
+	} else {
+		mergeReservations(l.committed, l.tentative)
+	}
+	l.tentative = make(map[string]*nodeAmounts)
+}
+
+// Rollback discards all tentative reservations, keeping committed ones
+func (l *Ledger) Rollback() {
+	l.tentative = make(map[string]*nodeAmounts)
+}
+
+// Reserved returns the resources reserved on a node, including tentative reservations and reservations of the
+// ledgers this one is a snapshot of
+func (l *Ledger) Reserved(nodeName string) *Resources {
+	total := &nodeAmounts{}
+	for layer := l; layer != nil; layer = layer.base {
+		if r, ok := layer.committed[nodeName]; ok {
+			total.merge(r)
+		}
+		if r, ok := layer.tentative[nodeName]; ok {
+			total.merge(r)
+		}
+	}
+	return total.resources()
+}
+
+// Changes returns the reservations made on the receiver, committed or not, excluding the reservations of the
+// ledgers it is a snapshot of
+func (l *Ledger) Changes() NodeGroupResources {
+	changes := make(map[string]*nodeAmounts, len(l.committed)+len(l.tentative))
+	mergeReservations(changes, l.committed)
+	mergeReservations(changes, l.tentative)
+	return toNodeGroupResources(changes)
+}
+
+// Resources returns all reservations, as returned by Reserved, for every node with a reservation
+func (l *Ledger) Resources() NodeGroupResources {
+	reserved := make(map[string]*nodeAmounts)
+	for layer := l; layer != nil; layer = layer.base {
+		mergeReservations(reserved, layer.committed)
+		mergeReservations(reserved, layer.tentative)
+	}
+	return toNodeGroupResources(reserved)
+}
+
+// ReservedVector is Reserved as a Vector with the given dimensions
+func (l *Ledger) ReservedVector(dimensions Dimensions, nodeName string) Vector {
+	reserved := make(Vector, len(dimensions))
+	l.SetReservedVector(dimensions, nodeName, reserved)
+	return reserved
+}
+
+// SetReservedVector is like ReservedVector, but overwrites v, which must have one value per dimension, instead of
+// allocating a new Vector
+func (l *Ledger) SetReservedVector(dimensions Dimensions, nodeName string, v Vector) {
+	for i := range v {
+		v[i] = 0
+	}
+	for layer := l; layer != nil; layer = layer.base {
+		if r, ok := layer.committed[nodeName]; ok {
+			r.addTo(dimensions, v)
+		}
+		if r, ok := layer.tentative[nodeName]; ok {
+			r.addTo(dimensions, v)
+		}
+	}
+}
+
+// ReservedNodes returns the names of all nodes with a reservation, in no particular order
+func (l *Ledger) ReservedNodes() []string {
+	seen := make(map[string]bool)
+	nodeNames := make([]string, 0)
+	for layer := l; layer != nil; layer = layer.base {
+		for _, reservations := range []map[string]*nodeAmounts{layer.committed, layer.tentative} {
+			for nodeName := range reservations {
+				if !seen[nodeName] {
+					seen[nodeName] = true
+					nodeNames = append(nodeNames, nodeName)
+				}
+			}
+		}
+	}
+	return nodeNames
+}
+
+func toNodeGroupResources(reservations map[string]*nodeAmounts) NodeGroupResources {
+	nodeGroupResources := make(NodeGroupResources, len(reservations))
+	for nodeName, r := range reservations {
+		nodeGroupResources[nodeName] = r.resources()
+	}
+	return nodeGroupResources
+}
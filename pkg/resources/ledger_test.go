@@ -0,0 +1,124 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestLedger(t *testing.T) {
+	initial := NodeGroupResources{"1": CreateResources(1, 1, 0)}
+	ledger := NewLedger(initial)
+	ledger.Reserve("1", CreateResources(1, 2, 0))
+	ledger.Reserve("2", CreateResources(3, 3, 0))
+	if !ledger.Reserved("1").Eq(CreateResources(2, 3, 0)) {
+		t.Fatalf("tentative reservation not included, got: %v", ledger.Reserved("1"))
+	}
+	if !initial["1"].Eq(CreateResources(1, 1, 0)) {
+		t.Fatalf("initial reservations were modified, got: %v", initial["1"])
+	}
+
+	ledger.Rollback()
+	expected := NodeGroupResources{"1": CreateResources(1, 1, 0)}
+	if !nodeGroupResourcesEq(ledger.Resources(), expected) {
+		t.Fatalf("rollback not applied, expected: %v, got: %v", expected, ledger.Resources())
+	}
+
+	ledger.Reserve("2", CreateResources(3, 3, 0))
+	ledger.Commit()
+	ledger.Reserve("2", CreateResources(1, 1, 0))
+	expected = NodeGroupResources{"1": CreateResources(1, 1, 0), "2": CreateResources(4, 4, 0)}
+	if !nodeGroupResourcesEq(ledger.Resources(), expected) {
+		t.Fatalf("commit not applied, expected: %v, got: %v", expected, ledger.Resources())
+	}
+	ledger.Rollback()
+	expected = NodeGroupResources{"1": CreateResources(1, 1, 0), "2": CreateResources(3, 3, 0)}
+	if !nodeGroupResourcesEq(ledger.Resources(), expected) {
+		t.Fatalf("rollback discarded committed reservations, expected: %v, got: %v", expected, ledger.Resources())
+	}
+}
+
+func TestLedgerSnapshot(t *testing.T) {
+	ledger := NewLedger(NodeGroupResources{"1": CreateResources(1, 1, 0)})
+	first := ledger.Snapshot()
+	second := ledger.Snapshot()
+	first.Reserve("1", CreateResources(2, 2, 0))
+	second.Reserve("2", CreateResources(3, 3, 0))
+
+	if !first.Reserved("1").Eq(CreateResources(3, 3, 0)) {
+		t.Fatalf("snapshot does not include base reservations, got: %v", first.Reserved("1"))
+	}
+	dimensions := DimensionsOf()
+	if vector := first.ReservedVector(dimensions, "1"); !reflect.DeepEqual(vector, dimensions.Vector(CreateResources(3, 3, 0))) {
+		t.Fatalf("reserved vector not equal, got: %v", vector)
+	}
+	if !second.Reserved("1").Eq(CreateResources(1, 1, 0)) || !ledger.Reserved("2").Eq(Zero()) {
+		t.Fatalf("snapshots are not independent")
+	}
+
+	expectedChanges := NodeGroupResources{"1": CreateResources(2, 2, 0)}
+	if !nodeGroupResourcesEq(first.Changes(), expectedChanges) {
+		t.Fatalf("changes not equal, expected: %v, got: %v", expectedChanges, first.Changes())
+	}
+	ledger.Merge(first)
+	if !ledger.Reserved("1").Eq(CreateResources(3, 3, 0)) {
+		t.Fatalf("changes of snapshot not applied, got: %v", ledger.Reserved("1"))
+	}
+}
+
+func TestLedgerVectors(t *testing.T) {
+	extendedResourceName := corev1.ResourceName("example.com/fpga")
+	executor := CreateResources(1, 2, 0)
+	executor.Set(extendedResourceName, *resource.NewQuantity(1, resource.DecimalSI))
+	dimensions := DimensionsOf(executor)
+
+	ledger := NewLedger(NodeGroupResources{"1": CreateResources(1, 1, 0)})
+	ledger.ReserveVector(dimensions, "1", dimensions.Vector(executor).Times(2))
+	ledger.Reserve("2", CreateResources(1, 1, 1))
+
+	expected := CreateResources(3, 5, 0)
+	expected.Set(extendedResourceName, *resource.NewQuantity(2, resource.DecimalSI))
+	if !ledger.Reserved("1").Eq(expected) {
+		t.Fatalf("reserved resources not equal, expected: %v, got: %v", expected, ledger.Reserved("1"))
+	}
+	if vector := ledger.ReservedVector(dimensions, "1"); !reflect.DeepEqual(vector, dimensions.Vector(expected)) {
+		t.Fatalf("reserved vector not equal, got: %v", vector)
+	}
+	if vector := ledger.ReservedVector(dimensions, "2"); !reflect.DeepEqual(vector, Vector{1000, 1, 1, 0}) {
+		t.Fatalf("reserved vector not equal, got: %v", vector)
+	}
+	nodeNames := ledger.ReservedNodes()
+	sort.Strings(nodeNames)
+	if !reflect.DeepEqual(nodeNames, []string{"1", "2"}) {
+		t.Fatalf("reserved nodes not equal, got: %v", nodeNames)
+	}
+}
+
+func nodeGroupResourcesEq(a, b NodeGroupResources) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for nodeName, r := range a {
+		if other, ok := b[nodeName]; !ok || !r.Eq(other) {
+			return false
+		}
+	}
+	return true
+}
@@ -16,6 +16,7 @@ package resources
 
 import (
 	"math"
+	"sort"
 	"time"
 
 	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
@@ -27,8 +28,16 @@ const (
 	zoneLabelPlaceholder = "default"
 )
 
-// UsageForNodes tallies resource usages per node from the given list of resource reservations
-func UsageForNodes(resourceReservations []*v1beta2.ResourceReservation) NodeGroupResources {
+var (
+	// DefaultZoneLabelKeys are the node labels the zone of a node is read from, in order of preference
+	DefaultZoneLabelKeys = []string{corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone}
+	// DefaultRegionLabelKeys are the node labels the region of a node is read from, in order of preference
+	DefaultRegionLabelKeys = []string{corev1.LabelTopologyRegion, corev1.LabelFailureDomainBetaRegion}
+)
+
+// UsageForNodes tallies resource usages per node from the given list of resource reservations. Only the given
+// extended resources are tracked besides CPU, Memory and NvidiaGPU.
+func UsageForNodes(resourceReservations []*v1beta2.ResourceReservation, extendedResourceNames ...corev1.ResourceName) NodeGroupResources {
 	res := NodeGroupResources(map[string]*Resources{})
 	for _, rr := range resourceReservations {
 		for _, reservation := range rr.Spec.Reservations {
@@ -36,32 +45,50 @@ func UsageForNodes(resourceReservations []*v1beta2.ResourceReservation) NodeGrou
 			if res[node] == nil {
 				res[node] = Zero()
 			}
-			res[node].AddFromReservation(&reservation)
+			res[node].AddFromReservation(&reservation, extendedResourceNames...)
 		}
 	}
 	return res
 }
 
-// AvailableForNodes finds available resources by subtracting current usage from allocatable per node
-func AvailableForNodes(nodes []*corev1.Node, currentUsage NodeGroupResources) NodeGroupResources {
+// AvailableForNodes finds available resources by subtracting current usage from allocatable per node. Only the
+// given extended resources are tracked besides CPU, Memory and NvidiaGPU.
+func AvailableForNodes(nodes []*corev1.Node, currentUsage NodeGroupResources, extendedResourceNames ...corev1.ResourceName) NodeGroupResources {
 	res := NodeGroupResources(make(map[string]*Resources, len(nodes)))
 	for _, n := range nodes {
 		currentUsageForNode, ok := currentUsage[n.Name]
 		if !ok {
 			currentUsageForNode = Zero()
 		}
-		res[n.Name] = subtractFromResourceList(n.Status.Allocatable, currentUsageForNode)
+		res[n.Name] = subtractFromResourceList(n.Status.Allocatable, currentUsageForNode, extendedResourceNames)
 	}
 	return res
 }
 
 // NodeSchedulingMetadataForNodes calculate available and schedulable resources. Available resources are computed by
 // subtracting current and overhead usage from allocatable per node. Schedulable resources are computed by subtracting
-// overhead usage from allocatable per node.
+// overhead usage from allocatable per node. Zones and regions are read from DefaultZoneLabelKeys and
+// DefaultRegionLabelKeys.
+//
+// Only CPU, Memory, NvidiaGPU and the given extended resources are tracked. Nodes list many other resources, such as
+// pods or attachable volumes, that applications do not request and that should not influence packing.
 func NodeSchedulingMetadataForNodes(
 	nodes []*corev1.Node,
 	currentUsage NodeGroupResources,
-	overheadUsage NodeGroupResources) NodeGroupSchedulingMetadata {
+	overheadUsage NodeGroupResources,
+	extendedResourceNames ...corev1.ResourceName) NodeGroupSchedulingMetadata {
+	return NodeSchedulingMetadataForNodesWithLabelKeys(nodes, currentUsage, overheadUsage, DefaultZoneLabelKeys, DefaultRegionLabelKeys, extendedResourceNames...)
+}
+
+// NodeSchedulingMetadataForNodesWithLabelKeys is like NodeSchedulingMetadataForNodes, but reads the zone and region
+// of each node from the first of zoneLabelKeys and regionLabelKeys respectively that the node has. Nodes without a
+// zone label are placed in a "default" zone, nodes without a region label have an empty region.
+func NodeSchedulingMetadataForNodesWithLabelKeys(
+	nodes []*corev1.Node,
+	currentUsage NodeGroupResources,
+	overheadUsage NodeGroupResources,
+	zoneLabelKeys, regionLabelKeys []string,
+	extendedResourceNames ...corev1.ResourceName) NodeGroupSchedulingMetadata {
 
 	nodeGroupSchedulingMetadata := make(NodeGroupSchedulingMetadata, len(nodes))
 	for _, node := range nodes {
@@ -75,10 +102,11 @@ func NodeSchedulingMetadataForNodes(
 		}
 		currentUsageForNode.Add(currentOverheadForNode)
 
-		zoneLabel, ok := node.Labels[corev1.LabelZoneFailureDomain]
+		zoneLabel, ok := firstLabel(node.Labels, zoneLabelKeys)
 		if !ok {
 			zoneLabel = zoneLabelPlaceholder
 		}
+		regionLabel, _ := firstLabel(node.Labels, regionLabelKeys)
 
 		nodeReady := false
 		for _, condition := range node.Status.Conditions {
@@ -87,18 +115,30 @@ func NodeSchedulingMetadataForNodes(
 			}
 		}
 		nodeGroupSchedulingMetadata[node.Name] = &NodeSchedulingMetadata{
-			AvailableResources:   subtractFromResourceList(node.Status.Allocatable, currentUsageForNode),
-			SchedulableResources: subtractFromResourceList(node.Status.Allocatable, currentOverheadForNode),
+			AvailableResources:   subtractFromResourceList(node.Status.Allocatable, currentUsageForNode, extendedResourceNames),
+			SchedulableResources: subtractFromResourceList(node.Status.Allocatable, currentOverheadForNode, extendedResourceNames),
 			CreationTimestamp:    node.CreationTimestamp.Time,
 			ZoneLabel:            zoneLabel,
+			RegionLabel:          regionLabel,
 			AllLabels:            node.Labels,
+			Annotations:          node.Annotations,
 			Unschedulable:        node.Spec.Unschedulable,
 			Ready:                nodeReady,
+			Taints:               node.Spec.Taints,
 		}
 	}
 	return nodeGroupSchedulingMetadata
 }
 
+func firstLabel(labels map[string]string, labelKeys []string) (string, bool) {
+	for _, labelKey := range labelKeys {
+		if value, ok := labels[labelKey]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
 // NodeGroupResources represents resources for a group of nodes
 type NodeGroupResources map[string]*Resources
 
@@ -134,24 +174,56 @@ func (nodesSchedulingMetadata NodeGroupSchedulingMetadata) SubtractUsageIfExists
 	}
 }
 
-func subtractFromResourceList(resourceList corev1.ResourceList, resources *Resources) *Resources {
+// WithUsageReleased returns a copy of the receiver with releasedResourcesByNodeName added back to the available
+// resources of the nodes that exist in the receiver. Only the metadata of those nodes is copied, the receiver is not
+// modified.
+func (nodesSchedulingMetadata NodeGroupSchedulingMetadata) WithUsageReleased(releasedResourcesByNodeName NodeGroupResources) NodeGroupSchedulingMetadata {
+	withReleased := make(NodeGroupSchedulingMetadata, len(nodesSchedulingMetadata))
+	for nodeName, nodeSchedulingMetadata := range nodesSchedulingMetadata {
+		releasedResources, ok := releasedResourcesByNodeName[nodeName]
+		if !ok {
+			withReleased[nodeName] = nodeSchedulingMetadata
+			continue
+		}
+		copied := *nodeSchedulingMetadata
+		copied.AvailableResources = nodeSchedulingMetadata.AvailableResources.Copy()
+		copied.AvailableResources.Add(releasedResources)
+		withReleased[nodeName] = &copied
+	}
+	return withReleased
+}
+
+func subtractFromResourceList(resourceList corev1.ResourceList, resources *Resources, extendedResourceNames []corev1.ResourceName) *Resources {
 	// (a - b) == -(b - a)
 	copyResources := resources.Copy()
-	resourcesToSubtractFrom := getResourcesFromResourceList(resourceList)
+	resourcesToSubtractFrom := getResourcesFromResourceList(resourceList, extendedResourceNames)
 	copyResources.CPU.Sub(resourcesToSubtractFrom.CPU)
 	copyResources.CPU.Neg()
 	copyResources.Memory.Sub(resourcesToSubtractFrom.Memory)
 	copyResources.Memory.Neg()
 	copyResources.NvidiaGPU.Sub(resourcesToSubtractFrom.NvidiaGPU)
 	copyResources.NvidiaGPU.Neg()
+	// usage of extended resources that are not tracked is dropped
+	used := copyResources.Extended
+	copyResources.Extended = nil
+	for _, name := range unionOfExtendedResourceNames(&resourcesToSubtractFrom) {
+		quantity := resourcesToSubtractFrom.Get(name)
+		if usedQuantity, ok := used[name]; ok {
+			quantity.Sub(usedQuantity)
+		}
+		copyResources.Set(name, quantity)
+	}
 	return copyResources
 }
 
-// Resources represents the CPU and Memory resource quantities
+// Resources represents the CPU, Memory and NvidiaGPU resource quantities. Any other resource, such as ephemeral
+// storage, hugepages or vendor devices, is kept in Extended. Functions that read resource lists only keep the
+// extended resources their caller opts into.
 type Resources struct {
 	CPU       resource.Quantity
 	Memory    resource.Quantity
 	NvidiaGPU resource.Quantity
+	Extended  map[corev1.ResourceName]resource.Quantity
 }
 
 // NodeSchedulingMetadata represents various parameters of a node that are considered in scheduling decisions
@@ -160,17 +232,30 @@ type NodeSchedulingMetadata struct {
 	SchedulableResources *Resources
 	CreationTimestamp    time.Time
 	ZoneLabel            string
+	RegionLabel          string
 	AllLabels            map[string]string
+	Annotations          map[string]string
 	Unschedulable        bool
 	Ready                bool
+	Taints               []corev1.Taint
 }
 
-func getResourcesFromResourceList(resourceList corev1.ResourceList) Resources {
-	return Resources{
+func getResourcesFromResourceList(resourceList corev1.ResourceList, extendedResourceNames []corev1.ResourceName) Resources {
+	resources := Resources{
 		CPU:       resourceList[corev1.ResourceCPU],
 		Memory:    resourceList[corev1.ResourceMemory],
 		NvidiaGPU: resourceList[v1beta2.ResourceNvidiaGPU],
 	}
+	for _, name := range extendedResourceNames {
+		if quantity, ok := resourceList[name]; ok && isExtendedResource(name) {
+			resources.Set(name, quantity)
+		}
+	}
+	return resources
+}
+
+func isExtendedResource(name corev1.ResourceName) bool {
+	return name != corev1.ResourceCPU && name != corev1.ResourceMemory && name != v1beta2.ResourceNvidiaGPU
 }
 
 // Zero returns a Resources object with quantities of zero
@@ -182,20 +267,114 @@ func Zero() *Resources {
 	}
 }
 
-// AddFromReservation modifies the receiver in place.
-func (r *Resources) AddFromReservation(reservation *v1beta2.Reservation) {
+// CreateResourcesFromResourceList creates a new Resources struct holding the CPU, Memory and NvidiaGPU quantities in
+// resourceList, as well as the quantities of the given extended resources
+func CreateResourcesFromResourceList(resourceList corev1.ResourceList, extendedResourceNames ...corev1.ResourceName) *Resources {
+	resources := Zero()
+	resources.AddFromResourceList(resourceList, extendedResourceNames...)
+	return resources
+}
+
+// Get returns the quantity of the named resource, or zero when it is not set
+func (r *Resources) Get(name corev1.ResourceName) resource.Quantity {
+	switch name {
+	case corev1.ResourceCPU:
+		return r.CPU
+	case corev1.ResourceMemory:
+		return r.Memory
+	case v1beta2.ResourceNvidiaGPU:
+		return r.NvidiaGPU
+	}
+	if quantity, ok := r.Extended[name]; ok {
+		return quantity
+	}
+	return *resource.NewQuantity(0, resource.DecimalSI)
+}
+
+// Set modifies the receiver in place to hold a copy of quantity for the named resource
+func (r *Resources) Set(name corev1.ResourceName, quantity resource.Quantity) {
+	switch name {
+	case corev1.ResourceCPU:
+		r.CPU = quantity.DeepCopy()
+	case corev1.ResourceMemory:
+		r.Memory = quantity.DeepCopy()
+	case v1beta2.ResourceNvidiaGPU:
+		r.NvidiaGPU = quantity.DeepCopy()
+	default:
+		if r.Extended == nil {
+			r.Extended = make(map[corev1.ResourceName]resource.Quantity)
+		}
+		r.Extended[name] = quantity.DeepCopy()
+	}
+}
+
+// ResourceNames returns CPU, Memory and NvidiaGPU followed by the names of the extended resources set on the
+// receiver in alphabetical order
+func (r *Resources) ResourceNames() []corev1.ResourceName {
+	names := make([]corev1.ResourceName, 0, 3+len(r.Extended))
+	names = append(names, corev1.ResourceCPU, corev1.ResourceMemory, v1beta2.ResourceNvidiaGPU)
+	return append(names, unionOfExtendedResourceNames(r)...)
+}
+
+// ToResourceList converts the receiver to a corev1.ResourceList, omitting zero quantities
+func (r *Resources) ToResourceList() corev1.ResourceList {
+	resourceList := make(corev1.ResourceList, 3+len(r.Extended))
+	for _, name := range r.ResourceNames() {
+		if quantity := r.Get(name); !quantity.IsZero() {
+			resourceList[name] = quantity.DeepCopy()
+		}
+	}
+	return resourceList
+}
+
+func unionOfExtendedResourceNames(resources ...*Resources) []corev1.ResourceName {
+	hasExtended := false
+	for _, r := range resources {
+		hasExtended = hasExtended || len(r.Extended) > 0
+	}
+	if !hasExtended {
+		return nil
+	}
+	seen := make(map[corev1.ResourceName]bool)
+	names := make([]corev1.ResourceName, 0)
+	for _, r := range resources {
+		for name := range r.Extended {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// AddFromReservation modifies the receiver in place. Only the given extended resources are added besides CPU,
+// Memory and NvidiaGPU.
+func (r *Resources) AddFromReservation(reservation *v1beta2.Reservation, extendedResourceNames ...corev1.ResourceName) {
 	r.CPU.Add(*reservation.Resources.CPU())
 	r.Memory.Add(*reservation.Resources.Memory())
 	r.NvidiaGPU.Add(*reservation.Resources.NvidiaGPU())
+	for _, name := range extendedResourceNames {
+		if quantity, ok := reservation.Resources[string(name)]; ok && isExtendedResource(name) && quantity != nil {
+			r.addExtended(name, *quantity)
+		}
+	}
 }
 
 // Copy returns a clone of the Resources object
 func (r *Resources) Copy() *Resources {
-	return &Resources{
+	copied := &Resources{
 		CPU:       r.CPU.DeepCopy(),
 		Memory:    r.Memory.DeepCopy(),
 		NvidiaGPU: r.NvidiaGPU.DeepCopy(),
 	}
+	for name, quantity := range r.Extended {
+		copied.Set(name, quantity)
+	}
+	return copied
 }
 
 // Add modifies the receiver in place.
@@ -203,6 +382,9 @@ func (r *Resources) Add(other *Resources) {
 	r.CPU.Add(other.CPU)
 	r.Memory.Add(other.Memory)
 	r.NvidiaGPU.Add(other.NvidiaGPU)
+	for name, quantity := range other.Extended {
+		r.addExtended(name, quantity)
+	}
 }
 
 // Sub modifies the receiver in place
@@ -210,39 +392,74 @@ func (r *Resources) Sub(other *Resources) {
 	r.CPU.Sub(other.CPU)
 	r.Memory.Sub(other.Memory)
 	r.NvidiaGPU.Sub(other.NvidiaGPU)
+	for name, quantity := range other.Extended {
+		current := r.Get(name)
+		current.Sub(quantity)
+		r.Set(name, current)
+	}
+}
+
+func (r *Resources) addExtended(name corev1.ResourceName, quantity resource.Quantity) {
+	current := r.Get(name)
+	current.Add(quantity)
+	r.Set(name, current)
 }
 
-// AddFromResourceList modified the receiver in place
-func (r *Resources) AddFromResourceList(resourceList corev1.ResourceList) {
-	otherResources := getResourcesFromResourceList(resourceList)
-	r.CPU.Add(otherResources.CPU)
-	r.Memory.Add(otherResources.Memory)
-	r.NvidiaGPU.Add(otherResources.NvidiaGPU)
+// AddFromResourceList modified the receiver in place. Only the given extended resources are added besides CPU,
+// Memory and NvidiaGPU.
+func (r *Resources) AddFromResourceList(resourceList corev1.ResourceList, extendedResourceNames ...corev1.ResourceName) {
+	otherResources := getResourcesFromResourceList(resourceList, extendedResourceNames)
+	r.Add(&otherResources)
 }
 
-// SetMaxResource modifies the receiver in place to set each resource to the greater value of itself or the corresponding resource in resourceList
-func (r *Resources) SetMaxResource(resourceList corev1.ResourceList) {
-	otherResources := getResourcesFromResourceList(resourceList)
-	if otherResources.CPU.Cmp(r.CPU) > 0 {
-		r.CPU = otherResources.CPU.DeepCopy()
+// SetMaxResource modifies the receiver in place to set each resource to the greater value of itself or the corresponding resource in resourceList.
+// Only the given extended resources are considered besides CPU, Memory and NvidiaGPU.
+func (r *Resources) SetMaxResource(resourceList corev1.ResourceList, extendedResourceNames ...corev1.ResourceName) {
+	otherResources := getResourcesFromResourceList(resourceList, extendedResourceNames)
+	for _, name := range otherResources.ResourceNames() {
+		if quantity := otherResources.Get(name); quantity.Cmp(r.Get(name)) > 0 {
+			r.Set(name, quantity)
+		}
 	}
-	if otherResources.Memory.Cmp(r.Memory) > 0 {
-		r.Memory = otherResources.Memory.DeepCopy()
+}
+
+// GreaterThan returns true if any of the resource quantities of this object are greater than those of other
+func (r *Resources) GreaterThan(other *Resources) bool {
+	if r.CPU.Cmp(other.CPU) > 0 || r.Memory.Cmp(other.Memory) > 0 || r.NvidiaGPU.Cmp(other.NvidiaGPU) > 0 {
+		return true
 	}
-	if otherResources.NvidiaGPU.Cmp(r.NvidiaGPU) > 0 {
-		r.NvidiaGPU = otherResources.NvidiaGPU.DeepCopy()
+	if len(r.Extended) == 0 && len(other.Extended) == 0 {
+		return false
 	}
+	return len(r.ExceededResources(other)) > 0
 }
 
-// GreaterThan returns true if any of CPU, Memory or NvidiaGPU quantities of this object are greater than those
-// of other
-func (r *Resources) GreaterThan(other *Resources) bool {
-	return r.CPU.Cmp(other.CPU) > 0 || r.Memory.Cmp(other.Memory) > 0 || r.NvidiaGPU.Cmp(other.NvidiaGPU) > 0
+// ExceededResources returns the names of the resources for which the quantity of this object is greater than that
+// of other, in the order of ResourceNames
+func (r *Resources) ExceededResources(other *Resources) []corev1.ResourceName {
+	exceeded := make([]corev1.ResourceName, 0)
+	names := append([]corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, v1beta2.ResourceNvidiaGPU}, unionOfExtendedResourceNames(r, other)...)
+	for _, name := range names {
+		quantity := r.Get(name)
+		if quantity.Cmp(other.Get(name)) > 0 {
+			exceeded = append(exceeded, name)
+		}
+	}
+	return exceeded
 }
 
-// Eq returns true if all of CPU, Memory and NvidiaGPU quantities are equal between this Resources object and other
+// Eq returns true if all resource quantities are equal between this Resources object and other
 func (r *Resources) Eq(other *Resources) bool {
-	return r.CPU.Cmp(other.CPU) == 0 && r.Memory.Cmp(other.Memory) == 0 && r.NvidiaGPU.Cmp(other.NvidiaGPU) == 0
+	if r.CPU.Cmp(other.CPU) != 0 || r.Memory.Cmp(other.Memory) != 0 || r.NvidiaGPU.Cmp(other.NvidiaGPU) != 0 {
+		return false
+	}
+	for _, name := range unionOfExtendedResourceNames(r, other) {
+		quantity := r.Get(name)
+		if quantity.Cmp(other.Get(name)) != 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // CreateResources creates a new Resources struct with given specs.
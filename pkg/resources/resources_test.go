@@ -17,6 +17,11 @@ package resources
 import (
 	"reflect"
 	"testing"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestAdd(t *testing.T) {
@@ -58,3 +63,174 @@ func TestSubZeroGpus(t *testing.T) {
 		t.Fatalf("difference not equal, expected: %+v, got: %+v", result, first)
 	}
 }
+
+func TestWithUsageReleased(t *testing.T) {
+	nodesSchedulingMetadata := NodeGroupSchedulingMetadata{
+		"1": CreateSchedulingMetadata(1, 2, 0, "zone1"),
+		"2": CreateSchedulingMetadata(3, 10, 0, "zone1"),
+	}
+	released := NodeGroupResources{"1": CreateResources(2, 4, 0), "3": CreateResources(1, 5, 0)}
+	withReleased := nodesSchedulingMetadata.WithUsageReleased(released)
+	if len(withReleased) != 2 {
+		t.Fatalf("expected 2 nodes, got: %+v", withReleased)
+	}
+	if !withReleased["1"].AvailableResources.Eq(CreateResources(3, 6, 0)) {
+		t.Fatalf("released usage not added, got: %+v", withReleased["1"].AvailableResources)
+	}
+	if !nodesSchedulingMetadata["1"].AvailableResources.Eq(CreateResources(1, 2, 0)) {
+		t.Fatalf("receiver modified, got: %+v", nodesSchedulingMetadata["1"].AvailableResources)
+	}
+	if withReleased["2"] != nodesSchedulingMetadata["2"] {
+		t.Fatalf("metadata of nodes without released usage should be shared")
+	}
+}
+
+func TestExtendedResources(t *testing.T) {
+	fpga := corev1.ResourceName("example.com/fpga")
+	hugepages := corev1.ResourceName("hugepages-2Mi")
+	extendedResourceNames := []corev1.ResourceName{corev1.ResourceEphemeralStorage, fpga, hugepages}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("8"),
+				corev1.ResourceMemory:           resource.MustParse("32Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+				fpga:                            resource.MustParse("2"),
+			},
+		},
+	}
+	usage := CreateResourcesFromResourceList(corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("1"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("40Gi"),
+		fpga:                            resource.MustParse("1"),
+		"example.com/untracked":         resource.MustParse("1"),
+	}, append(extendedResourceNames, "example.com/untracked")...)
+	metadata := NodeSchedulingMetadataForNodes([]*corev1.Node{node}, NodeGroupResources{"n1": usage}, NodeGroupResources{}, extendedResourceNames...)["n1"]
+
+	expectedAvailable := CreateResourcesFromResourceList(corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("7"),
+		corev1.ResourceMemory:           resource.MustParse("32Gi"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("60Gi"),
+		fpga:                            resource.MustParse("1"),
+	}, extendedResourceNames...)
+	if !metadata.AvailableResources.Eq(expectedAvailable) {
+		t.Fatalf("available resources not equal, expected: %+v, got: %+v", expectedAvailable, metadata.AvailableResources)
+	}
+	if expectedNames := []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, v1beta2.ResourceNvidiaGPU, corev1.ResourceEphemeralStorage, fpga}; !reflect.DeepEqual(metadata.AvailableResources.ResourceNames(), expectedNames) {
+		t.Fatalf("tracked resources not equal, expected: %v, got: %v", expectedNames, metadata.AvailableResources.ResourceNames())
+	}
+
+	fitting := CreateResourcesFromResourceList(corev1.ResourceList{corev1.ResourceEphemeralStorage: resource.MustParse("60Gi"), fpga: resource.MustParse("1")}, extendedResourceNames...)
+	if fitting.GreaterThan(metadata.AvailableResources) {
+		t.Fatalf("expected %+v to fit in %+v", fitting, metadata.AvailableResources)
+	}
+	tooLarge := CreateResourcesFromResourceList(corev1.ResourceList{fpga: resource.MustParse("2")}, extendedResourceNames...)
+	if !tooLarge.GreaterThan(metadata.AvailableResources) {
+		t.Fatalf("expected %+v not to fit in %+v", tooLarge, metadata.AvailableResources)
+	}
+	if exceeded := tooLarge.ExceededResources(metadata.AvailableResources); !reflect.DeepEqual(exceeded, []corev1.ResourceName{fpga}) {
+		t.Fatalf("exceeded resources not equal, expected: %v, got: %v", []corev1.ResourceName{fpga}, exceeded)
+	}
+	missing := CreateResourcesFromResourceList(corev1.ResourceList{hugepages: resource.MustParse("2Mi")}, extendedResourceNames...)
+	if !missing.GreaterThan(metadata.AvailableResources) {
+		t.Fatalf("expected %+v not to fit in %+v", missing, metadata.AvailableResources)
+	}
+}
+
+func TestExtendedResourcesAreOptIn(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse("8"),
+				corev1.ResourceMemory:           resource.MustParse("32Gi"),
+				corev1.ResourcePods:             resource.MustParse("110"),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+		},
+	}
+	// usage of resources that are not tracked must not make them unavailable
+	usage := NodeGroupResources{"n1": &Resources{Extended: map[corev1.ResourceName]resource.Quantity{
+		corev1.ResourceEphemeralStorage: resource.MustParse("200Gi"),
+	}}}
+	metadata := NodeSchedulingMetadataForNodes([]*corev1.Node{node}, usage, NodeGroupResources{})["n1"]
+	if len(metadata.AvailableResources.Extended) != 0 || len(metadata.SchedulableResources.Extended) != 0 {
+		t.Fatalf("expected no extended resources, got: %+v and %+v", metadata.AvailableResources, metadata.SchedulableResources)
+	}
+
+	request := CreateResourcesFromResourceList(corev1.ResourceList{
+		corev1.ResourceCPU:              resource.MustParse("1"),
+		corev1.ResourceEphemeralStorage: resource.MustParse("1Gi"),
+	})
+	if len(request.Extended) != 0 {
+		t.Fatalf("expected no extended resources, got: %+v", request)
+	}
+	if request.GreaterThan(metadata.AvailableResources) {
+		t.Fatalf("expected %+v to fit in %+v", request, metadata.AvailableResources)
+	}
+
+	ephemeralStorage := resource.MustParse("1Gi")
+	fpgas := resource.MustParse("2")
+	reservation := &v1beta2.Reservation{Resources: v1beta2.ResourceList{
+		string(corev1.ResourceCPU):              resource.NewQuantity(1, resource.DecimalSI),
+		string(corev1.ResourceEphemeralStorage): &ephemeralStorage,
+		"example.com/fpga":                      &fpgas,
+	}}
+	reserved := Zero()
+	reserved.AddFromReservation(reservation, "example.com/fpga")
+	expected := CreateResources(1, 0, 0)
+	expected.Set("example.com/fpga", fpgas)
+	if !reserved.Eq(expected) {
+		t.Fatalf("expected only tracked extended resources, expected: %+v, got: %+v", expected, reserved)
+	}
+}
+
+func TestZoneAndRegionLabels(t *testing.T) {
+	newNode := func(name string, labels map[string]string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	nodes := []*corev1.Node{
+		newNode("stable", map[string]string{corev1.LabelTopologyZone: "zone1", corev1.LabelTopologyRegion: "region1"}),
+		newNode("beta", map[string]string{corev1.LabelFailureDomainBetaZone: "zone2", corev1.LabelFailureDomainBetaRegion: "region2"}),
+		newNode("both", map[string]string{corev1.LabelTopologyZone: "zone3", corev1.LabelFailureDomainBetaZone: "old-zone3"}),
+		newNode("custom", map[string]string{"example.com/zone": "zone4"}),
+	}
+
+	tests := []struct {
+		name            string
+		metadata        NodeGroupSchedulingMetadata
+		expectedZones   map[string]string
+		expectedRegions map[string]string
+	}{{
+		name:            "default label keys",
+		metadata:        NodeSchedulingMetadataForNodes(nodes, NodeGroupResources{}, NodeGroupResources{}),
+		expectedZones:   map[string]string{"stable": "zone1", "beta": "zone2", "both": "zone3", "custom": "default"},
+		expectedRegions: map[string]string{"stable": "region1", "beta": "region2", "both": "", "custom": ""},
+	}, {
+		name: "custom label keys",
+		metadata: NodeSchedulingMetadataForNodesWithLabelKeys(
+			nodes, NodeGroupResources{}, NodeGroupResources{}, []string{"example.com/zone", corev1.LabelFailureDomainBetaZone}, nil),
+		expectedZones:   map[string]string{"stable": "default", "beta": "zone2", "both": "old-zone3", "custom": "zone4"},
+		expectedRegions: map[string]string{"stable": "", "beta": "", "both": "", "custom": ""},
+	},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zones := make(map[string]string)
+			regions := make(map[string]string)
+			for nodeName, nodeSchedulingMetadata := range test.metadata {
+				zones[nodeName] = nodeSchedulingMetadata.ZoneLabel
+				regions[nodeName] = nodeSchedulingMetadata.RegionLabel
+			}
+			if !reflect.DeepEqual(zones, test.expectedZones) {
+				t.Fatalf("zones not equal, expected: %v, got: %v", test.expectedZones, zones)
+			}
+			if !reflect.DeepEqual(regions, test.expectedRegions) {
+				t.Fatalf("regions not equal, expected: %v, got: %v", test.expectedRegions, regions)
+			}
+		})
+	}
+}
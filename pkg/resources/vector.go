@@ -0,0 +1,180 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"math"
+
+	"github.com/palantir/k8s-spark-scheduler-lib/pkg/apis/sparkscheduler/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Dimensions is an ordered list of resource names that a Vector is indexed by. The first three dimensions are
+// always CPU, Memory and NvidiaGPU.
+type Dimensions []corev1.ResourceName
+
+// Vector is an int64 representation of Resources, used by binpacking to avoid resource.Quantity arithmetic in tight
+// loops. CPU is stored in millicores and every other resource in its base unit, e.g. bytes for Memory. Values that
+// do not fit an int64 are clamped.
+type Vector []int64
+
+// DimensionsOf returns CPU, Memory and NvidiaGPU followed by the extended resources set on any of resources, in
+// alphabetical order
+func DimensionsOf(resources ...*Resources) Dimensions {
+	extendedResourceNames := unionOfExtendedResourceNames(resources...)
+	if len(extendedResourceNames) == 0 {
+		// capped so that appending to the shared slice copies it
+		return coreDimensions[:3:3]
+	}
+	dimensions := make(Dimensions, 3, 3+len(extendedResourceNames))
+	copy(dimensions, coreDimensions)
+	return append(dimensions, extendedResourceNames...)
+}
+
+var (
+	coreDimensions   = Dimensions{corev1.ResourceCPU, corev1.ResourceMemory, v1beta2.ResourceNvidiaGPU}
+	minMilliQuantity = *resource.NewMilliQuantity(math.MinInt64, resource.DecimalSI)
+	maxMilliQuantity = *resource.NewMilliQuantity(math.MaxInt64, resource.DecimalSI)
+)
+
+// Vector converts r to a Vector, ignoring any resource that is not part of the dimensions
+func (d Dimensions) Vector(r *Resources) Vector {
+	vector := make(Vector, len(d))
+	d.SetVector(vector, r)
+	return vector
+}
+
+// SetVector is like Vector, but overwrites v, which must have one value per dimension, instead of allocating a new
+// Vector
+func (d Dimensions) SetVector(v Vector, r *Resources) {
+	v[0] = quantityToInt64(r.CPU, resource.Milli)
+	v[1] = quantityToInt64(r.Memory, 0)
+	v[2] = quantityToInt64(r.NvidiaGPU, 0)
+	for i := 3; i < len(d); i++ {
+		v[i] = 0
+		if quantity, ok := r.Extended[d[i]]; ok {
+			v[i] = quantityToInt64(quantity, 0)
+		}
+	}
+}
+
+// Resources converts v back to Resources
+func (d Dimensions) Resources(v Vector) *Resources {
+	r := &Resources{
+		CPU:       *resource.NewMilliQuantity(v[0], resource.DecimalSI),
+		Memory:    *resource.NewQuantity(v[1], resource.BinarySI),
+		NvidiaGPU: *resource.NewQuantity(v[2], resource.DecimalSI),
+	}
+	for i := 3; i < len(d); i++ {
+		r.Set(d[i], *resource.NewQuantity(v[i], resource.DecimalSI))
+	}
+	return r
+}
+
+func quantityToInt64(quantity resource.Quantity, scale resource.Scale) int64 {
+	// most quantities are well within range, and can be converted without any floating point arithmetic
+	if value, ok := quantity.AsInt64(); ok {
+		if scale == 0 {
+			return value
+		}
+		if scale == resource.Milli && value > math.MinInt64/1000 && value < math.MaxInt64/1000 {
+			return value * 1000
+		}
+	}
+	if scale == resource.Milli && quantity.Cmp(minMilliQuantity) > 0 && quantity.Cmp(maxMilliQuantity) < 0 {
+		return quantity.MilliValue()
+	}
+	// ScaledValue silently overflows, so clamp values that are out of range before converting them
+	approximate := quantity.AsApproximateFloat64() * math.Pow10(-int(scale))
+	if approximate >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	if approximate <= math.MinInt64 {
+		return math.MinInt64
+	}
+	return quantity.ScaledValue(scale)
+}
+
+// Copy returns a clone of the Vector
+func (v Vector) Copy() Vector {
+	return append(make(Vector, 0, len(v)), v...)
+}
+
+// Add modifies the receiver in place. Values that do not fit an int64 are clamped.
+func (v Vector) Add(other Vector) {
+	for i := range v {
+		v[i] = addInt64(v[i], other[i])
+	}
+}
+
+// Sub modifies the receiver in place. Values that do not fit an int64 are clamped.
+func (v Vector) Sub(other Vector) {
+	for i := range v {
+		if other[i] == math.MinInt64 {
+			v[i] = addInt64(addInt64(v[i], math.MaxInt64), 1)
+		} else {
+			v[i] = addInt64(v[i], -other[i])
+		}
+	}
+}
+
+// Times returns a new Vector with every value of the receiver multiplied by n. Values that do not fit an int64 are
+// clamped.
+func (v Vector) Times(n int) Vector {
+	times := make(Vector, len(v))
+	for i := range v {
+		times[i] = mulInt64(v[i], int64(n))
+	}
+	return times
+}
+
+// addInt64 returns a + b, clamped to the range of int64
+func addInt64(a, b int64) int64 {
+	sum := a + b
+	if b > 0 && sum < a {
+		return math.MaxInt64
+	}
+	if b < 0 && sum > a {
+		return math.MinInt64
+	}
+	return sum
+}
+
+// mulInt64 returns a * b, clamped to the range of int64
+func mulInt64(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	product := a * b
+	// the division does not detect the one overflow of a division, MinInt64 / -1
+	if product/b != a || (b == -1 && a == math.MinInt64) {
+		if (a > 0) == (b > 0) {
+			return math.MaxInt64
+		}
+		return math.MinInt64
+	}
+	return product
+}
+
+// GreaterThan returns true if any value of the receiver is greater than the corresponding value of other
+func (v Vector) GreaterThan(other Vector) bool {
+	for i := range v {
+		if v[i] > other[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2019 Palantir Technologies. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestVector(t *testing.T) {
+	extendedResourceName := corev1.ResourceName("example.com/fpga")
+	r := CreateResources(2, 1024, 1)
+	r.Set(extendedResourceName, *resource.NewQuantity(3, resource.DecimalSI))
+	r.CPU = resource.MustParse("1500m")
+
+	dimensions := DimensionsOf(r, CreateResources(1, 1, 1))
+	expectedDimensions := Dimensions{corev1.ResourceCPU, corev1.ResourceMemory, "nvidia.com/gpu", extendedResourceName}
+	if !reflect.DeepEqual(dimensions, expectedDimensions) {
+		t.Fatalf("dimensions not equal, expected: %v, got: %v", expectedDimensions, dimensions)
+	}
+
+	vector := dimensions.Vector(r)
+	expectedVector := Vector{1500, 1024, 1, 3}
+	if !reflect.DeepEqual(vector, expectedVector) {
+		t.Fatalf("vector not equal, expected: %v, got: %v", expectedVector, vector)
+	}
+	if !dimensions.Resources(vector).Eq(r) {
+		t.Fatalf("resources not equal, expected: %v, got: %v", r, dimensions.Resources(vector))
+	}
+
+	sum := vector.Copy()
+	sum.Add(vector.Times(2))
+	sum.Sub(vector)
+	if !reflect.DeepEqual(sum, vector.Times(2)) {
+		t.Fatalf("vector not equal, expected: %v, got: %v", vector.Times(2), sum)
+	}
+	if vector.GreaterThan(sum) || !sum.GreaterThan(vector) {
+		t.Fatalf("unexpected comparison between %v and %v", vector, sum)
+	}
+}
+
+func TestSetVectorOverwrites(t *testing.T) {
+	extendedResourceName := corev1.ResourceName("example.com/fpga")
+	withExtended := CreateResources(2, 1024, 1)
+	withExtended.Set(extendedResourceName, *resource.NewQuantity(3, resource.DecimalSI))
+	dimensions := DimensionsOf(withExtended)
+
+	vector := dimensions.Vector(withExtended)
+	dimensions.SetVector(vector, CreateResources(1, 512, 0))
+	expectedVector := Vector{1000, 512, 0, 0}
+	if !reflect.DeepEqual(vector, expectedVector) {
+		t.Fatalf("vector not overwritten, expected: %v, got: %v", expectedVector, vector)
+	}
+
+	// whole cores just below the int64 range in millicores are converted exactly, larger ones are clamped
+	dimensions.SetVector(vector, CreateResources(math.MaxInt64/1000, 0, 0))
+	if vector[0] != math.MaxInt64/1000*1000 {
+		t.Fatalf("expected exact conversion, got: %v", vector[0])
+	}
+	dimensions.SetVector(vector, CreateResources(math.MaxInt64/1000+1, 0, 0))
+	if vector[0] != math.MaxInt64 {
+		t.Fatalf("expected value to be clamped, got: %v", vector[0])
+	}
+}
+
+func TestVectorClampsLargeQuantities(t *testing.T) {
+	r := CreateResources(math.MaxInt64, math.MaxInt64, 0)
+	vector := DimensionsOf(r).Vector(r)
+	if vector[0] != math.MaxInt64 || vector[1] != math.MaxInt64 {
+		t.Fatalf("expected values to be clamped, got: %v", vector)
+	}
+}
+
+func TestVectorArithmeticSaturates(t *testing.T) {
+	large := Vector{math.MaxInt64 / 2, math.MinInt64 / 2, 3}
+	if times := large.Times(3); !reflect.DeepEqual(times, Vector{math.MaxInt64, math.MinInt64, 9}) {
+		t.Fatalf("expected multiplication to saturate, got: %v", times)
+	}
+	if times := large.Times(-3); !reflect.DeepEqual(times, Vector{math.MinInt64, math.MaxInt64, -9}) {
+		t.Fatalf("expected multiplication to saturate, got: %v", times)
+	}
+	if times := (Vector{math.MinInt64}).Times(-1); !reflect.DeepEqual(times, Vector{math.MaxInt64}) {
+		t.Fatalf("expected multiplication to saturate, got: %v", times)
+	}
+
+	sum := Vector{math.MaxInt64, math.MinInt64, 1}
+	sum.Add(Vector{1, -1, 1})
+	if !reflect.DeepEqual(sum, Vector{math.MaxInt64, math.MinInt64, 2}) {
+		t.Fatalf("expected addition to saturate, got: %v", sum)
+	}
+	sum.Sub(Vector{-1, 1, math.MinInt64})
+	if !reflect.DeepEqual(sum, Vector{math.MaxInt64, math.MinInt64, math.MaxInt64}) {
+		t.Fatalf("expected subtraction to saturate, got: %v", sum)
+	}
+}